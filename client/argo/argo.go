@@ -3,57 +3,169 @@ package argo
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 
 	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	argoclient "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned"
 	"github.com/bsonger/devflow-common/client/logging"
+	"github.com/bsonger/devflow-common/model"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-var ArgoCdClient *argoclient.Clientset
+// ArgoCdClient 使用接口类型，测试时可以注入 fake clientset
+var ArgoCdClient argoclient.Interface
 
 const (
-	namespace = "argo-cd"
+	// ArgoCD 维护的 labels/annotations 前缀
+	argoPrefix = "argocd.argoproj.io/"
 )
 
+// Namespace 返回 ArgoCD Application 所在的 namespace，来自 model.ArgoConfig
+func Namespace() string {
+	return model.GetArgoConfig().ArgoNamespace()
+}
+
 // InitArgoCdClient 初始化 ArgoCD client
 func InitArgoCdClient(config *rest.Config) error {
-	var err error
-	ArgoCdClient, err = argoclient.NewForConfig(config)
+	client, err := NewArgoCdClient(config)
 	if err != nil {
-		return fmt.Errorf("failed to create argo cd client: %w", err)
+		return err
 	}
+	SetArgoCdClient(client)
 	logging.Logger.Info("argo cd client initialized")
 	return nil
 }
 
+// NewArgoCdClient 根据 rest.Config 创建 ArgoCD client
+func NewArgoCdClient(config *rest.Config) (argoclient.Interface, error) {
+	client, err := argoclient.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create argo cd client: %w", err)
+	}
+	return client, nil
+}
+
+// SetArgoCdClient 注入 client，例如测试中的 fake clientset
+func SetArgoCdClient(client argoclient.Interface) {
+	ArgoCdClient = client
+}
+
 // CreateApplication 创建或更新 ArgoCD Application
 func CreateApplication(ctx context.Context, app *appv1.Application) error {
-	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(namespace)
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
 
 	_, err := applications.Create(ctx, app, metav1.CreateOptions{})
 	return err
 }
 
+// UpdateApplication 更新已存在的 ArgoCD Application，resourceVersion 冲突时重试
 func UpdateApplication(ctx context.Context, app *appv1.Application) error {
-	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(namespace)
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
 
-	current, err := applications.Get(ctx, app.Name, metav1.GetOptions{})
-	if err != nil {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current, err := applications.Get(ctx, app.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		// 保持 name/namespace/resourceVersion，替换 spec
+		if !mergeApplication(current, app) {
+			return nil
+		}
+		_, err = applications.Update(ctx, current, metav1.UpdateOptions{})
 		return err
+	})
+}
+
+// ApplyApplication 幂等地创建或更新 ArgoCD Application
+// 不存在则创建，存在则只替换 spec/labels/annotations，保留 ArgoCD 维护的
+// operation、status 和 finalizers；resourceVersion 冲突时按 backoff 重试
+// 返回值表示是否真的有变化
+func ApplyApplication(ctx context.Context, app *appv1.Application) (bool, error) {
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
+
+	changed := false
+	err := retry.OnError(retry.DefaultBackoff, isRetryable, func() error {
+		current, err := applications.Get(ctx, app.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			desired := app.DeepCopy()
+			desired.Namespace = Namespace()
+			desired.ResourceVersion = ""
+			desired.Operation = nil
+			desired.Status = appv1.ApplicationStatus{}
+			if _, err := applications.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			changed = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !mergeApplication(current, app) {
+			changed = false
+			return nil
+		}
+		if _, err := applications.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// 并发创建 (AlreadyExists) 和 resourceVersion 冲突都重新 Get 后重试
+func isRetryable(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
+
+// mergeApplication 把 desired 的 spec/labels/annotations/finalizers 合并进 current
+// ArgoCD 自己维护的 argocd.argoproj.io/ 前缀的 labels/annotations 会保留
+func mergeApplication(current, desired *appv1.Application) bool {
+	spec := desired.Spec.DeepCopy()
+	labels := mergeOwned(current.Labels, desired.Labels)
+	annotations := mergeOwned(current.Annotations, desired.Annotations)
+	finalizers := current.Finalizers
+	for _, f := range desired.Finalizers {
+		if !slices.Contains(finalizers, f) {
+			finalizers = append(finalizers, f)
+		}
 	}
 
-	// 3. 保持 name/namespace，替换 spec
-	current.Spec = app.Spec
-	current.Annotations = app.Annotations
-	current.Labels = app.Labels
+	if equality.Semantic.DeepEqual(current.Spec, *spec) &&
+		equality.Semantic.DeepEqual(current.Labels, labels) &&
+		equality.Semantic.DeepEqual(current.Annotations, annotations) &&
+		len(finalizers) == len(current.Finalizers) {
+		return false
+	}
 
-	// ⚠️ 关键：保留 resourceVersion
-	// Kubernetes Update 必须要这个字段
-	// current.ResourceVersion 已经是 GET 回来的，直接保留即可。
+	current.Spec = *spec
+	current.Labels = labels
+	current.Annotations = annotations
+	current.Finalizers = finalizers
+	return true
+}
 
-	// 4. Update
-	_, err = applications.Update(ctx, current, metav1.UpdateOptions{})
-	return err
+func mergeOwned(current, desired map[string]string) map[string]string {
+	merged := make(map[string]string, len(desired))
+	for k, v := range current {
+		if strings.HasPrefix(k, argoPrefix) {
+			merged[k] = v
+		}
+	}
+	for k, v := range desired {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
 }
@@ -0,0 +1,137 @@
+// Package argotest 基于生成的 fake clientset 提供 client/argo 的测试工具，
+// 可以模拟 Application 的 sync / health 状态变化
+package argotest
+
+import (
+	"context"
+	"time"
+
+	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argofake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/bsonger/devflow-common/client/argo"
+)
+
+// Harness 持有 fake clientset，并已注入到 client/argo
+type Harness struct {
+	Argo *argofake.Clientset
+
+	watches chan struct{}
+}
+
+// New 创建 fake clientset 并注入 client/argo
+func New(objects ...runtime.Object) *Harness {
+	h := &Harness{
+		Argo:    argofake.NewSimpleClientset(objects...),
+		watches: make(chan struct{}, 16),
+	}
+	// fake clientset 不会补发 watch 建立之前的事件，记录 watch 的建立以便测试按顺序修改状态
+	h.Argo.PrependWatchReactor("applications", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := h.Argo.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err == nil {
+			select {
+			case h.watches <- struct{}{}:
+			default:
+			}
+		}
+		return true, w, err
+	})
+	argo.SetArgoCdClient(h.Argo)
+	return h
+}
+
+// WaitForWatch 等待有调用方（例如 WaitForApplication）开始 watch Application
+func (h *Harness) WaitForWatch(ctx context.Context) error {
+	select {
+	case <-h.watches:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetSync 模拟 ArgoCD 按当前 spec 完成一次比对，修改 sync 状态和 revision
+func (h *Harness) SetSync(ctx context.Context, name string, status appv1.SyncStatusCode, revision string) (*appv1.Application, error) {
+	return h.update(ctx, name, func(app *appv1.Application) {
+		app.Status.Sync.Status = status
+		app.Status.Sync.Revision = revision
+		app.Status.Sync.ComparedTo = appv1.ComparedTo{
+			Destination: app.Spec.Destination,
+		}
+		if app.Spec.HasMultipleSources() {
+			app.Status.Sync.ComparedTo.Sources = app.Spec.Sources
+		} else {
+			app.Status.Sync.ComparedTo.Source = app.Spec.GetSource()
+		}
+		now := metav1.NewTime(time.Now())
+		app.Status.ReconciledAt = &now
+	})
+}
+
+// SetHealth 修改 Application 的 health 状态
+func (h *Harness) SetHealth(ctx context.Context, name string, status health.HealthStatusCode) (*appv1.Application, error) {
+	return h.update(ctx, name, func(app *appv1.Application) {
+		now := metav1.NewTime(time.Now())
+		app.Status.Health.Status = status
+		app.Status.Health.LastTransitionTime = &now
+	})
+}
+
+// SetResourceHealth 设置（或新增）某个受管资源的 sync / health 状态
+func (h *Harness) SetResourceHealth(ctx context.Context, name string, res appv1.ResourceStatus) (*appv1.Application, error) {
+	return h.update(ctx, name, func(app *appv1.Application) {
+		for i, r := range app.Status.Resources {
+			if r.Group == res.Group && r.Kind == res.Kind && r.Namespace == res.Namespace && r.Name == res.Name {
+				app.Status.Resources[i] = res
+				return
+			}
+		}
+		app.Status.Resources = append(app.Status.Resources, res)
+	})
+}
+
+// SetOperationPhase 设置当前 operation 的阶段，结束阶段会同时清空 app.Operation
+func (h *Harness) SetOperationPhase(ctx context.Context, name string, phase synccommon.OperationPhase, message string) (*appv1.Application, error) {
+	return h.update(ctx, name, func(app *appv1.Application) {
+		now := metav1.NewTime(time.Now())
+		if app.Status.OperationState == nil {
+			app.Status.OperationState = &appv1.OperationState{StartedAt: now}
+			if app.Operation != nil {
+				app.Status.OperationState.Operation = *app.Operation
+			}
+		}
+		app.Status.OperationState.Phase = phase
+		app.Status.OperationState.Message = message
+		if phase.Completed() {
+			app.Status.OperationState.FinishedAt = &now
+			app.Operation = nil
+		}
+	})
+}
+
+// Rollout 模拟一次完整的成功发布：Synced + Healthy
+func (h *Harness) Rollout(ctx context.Context, name, revision string) (*appv1.Application, error) {
+	if _, err := h.SetOperationPhase(ctx, name, synccommon.OperationSucceeded, "successfully synced"); err != nil {
+		return nil, err
+	}
+	if _, err := h.SetSync(ctx, name, appv1.SyncStatusCodeSynced, revision); err != nil {
+		return nil, err
+	}
+	return h.SetHealth(ctx, name, health.HealthStatusHealthy)
+}
+
+func (h *Harness) update(ctx context.Context, name string, mutate func(app *appv1.Application)) (*appv1.Application, error) {
+	applications := h.Argo.ArgoprojV1alpha1().Applications(argo.Namespace())
+	app, err := applications.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	mutate(app)
+	return applications.Update(ctx, app, metav1.UpdateOptions{})
+}
@@ -0,0 +1,174 @@
+package argotest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bsonger/devflow-common/client/argo"
+	"github.com/bsonger/devflow-common/client/argo/argotest"
+	"github.com/bsonger/devflow-common/model"
+)
+
+func newApplication(name, manifestID string) *appv1.Application {
+	source := appv1.ApplicationSource{
+		RepoURL: "https://git.example.com/devflow/apps.git",
+		Plugin: &appv1.ApplicationSourcePlugin{
+			Name: "plugin",
+			Parameters: appv1.ApplicationSourcePluginParameters{
+				{Name: "manifest-id", String_: &manifestID},
+			},
+		},
+	}
+	return &appv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: argo.Namespace()},
+		Spec: appv1.ApplicationSpec{
+			Source:      &source,
+			Destination: appv1.ApplicationDestination{Server: "https://kubernetes.default.svc", Namespace: "demo"},
+			Project:     "default",
+		},
+	}
+}
+
+// waitAsync 在后台调用 WaitForApplication，并等待 watch 建立
+func waitAsync(t *testing.T, h *argotest.Harness, name string, opts argo.WaitOptions) <-chan *argo.WaitResult {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	out := make(chan *argo.WaitResult, 1)
+	go func() {
+		result, err := argo.WaitForApplication(ctx, name, opts)
+		if err != nil {
+			t.Errorf("WaitForApplication: %v", err)
+		}
+		out <- result
+	}()
+	if err := h.WaitForWatch(ctx); err != nil {
+		t.Fatalf("watch not started: %v", err)
+	}
+	return out
+}
+
+func TestWaitForApplicationRollout(t *testing.T) {
+	ctx := context.Background()
+
+	// 上一次发布的状态：Synced + Healthy，但比对的是旧的 manifest-id
+	app := newApplication("demo-dev", "new")
+	oldSource := app.Spec.Source.DeepCopy()
+	oldID := "old"
+	oldSource.Plugin.Parameters[0].String_ = &oldID
+	app.Status.Sync = appv1.SyncStatus{Status: appv1.SyncStatusCodeSynced, Revision: "r1", ComparedTo: appv1.ComparedTo{Source: *oldSource}}
+	app.Status.Health.Status = health.HealthStatusHealthy
+
+	h := argotest.New(app)
+	done := waitAsync(t, h, app.Name, argo.WaitOptions{Timeout: 5 * time.Second})
+
+	select {
+	case result := <-done:
+		t.Fatalf("returned before argocd compared the new spec: %+v", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := h.Rollout(ctx, app.Name, "r2"); err != nil {
+		t.Fatal(err)
+	}
+	result := <-done
+	if result.Phase != argo.WaitSucceeded {
+		t.Fatalf("phase = %s, want %s (%s)", result.Phase, argo.WaitSucceeded, result.Message)
+	}
+	if result.Revision != "r2" {
+		t.Errorf("revision = %s, want r2", result.Revision)
+	}
+}
+
+func TestWaitForApplicationIgnoresEarlierOperation(t *testing.T) {
+	ctx := context.Background()
+
+	app := newApplication("demo-staging", "m1")
+	app.Status.OperationState = &appv1.OperationState{
+		Phase:     synccommon.OperationFailed,
+		Message:   "previous upgrade failed",
+		StartedAt: metav1.NewTime(time.Now().Add(-10 * time.Second)),
+	}
+
+	h := argotest.New(app)
+	done := waitAsync(t, h, app.Name, argo.WaitOptions{Timeout: 5 * time.Second})
+
+	if _, err := h.SetSync(ctx, app.Name, appv1.SyncStatusCodeOutOfSync, "r1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-done:
+		t.Fatalf("failed by an operation that started before the wait: %+v", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := h.SetSync(ctx, app.Name, appv1.SyncStatusCodeSynced, "r1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.SetHealth(ctx, app.Name, health.HealthStatusDegraded); err != nil {
+		t.Fatal(err)
+	}
+	result := <-done
+	if result.Phase != argo.WaitFailed || result.Health != health.HealthStatusDegraded {
+		t.Fatalf("result = %+v, want failed with degraded health", result)
+	}
+}
+
+type teardownStore struct {
+	status  string
+	deleted bool
+}
+
+func (s *teardownStore) UpdateApplicationStatus(_ context.Context, _ primitive.ObjectID, status string) error {
+	s.status = status
+	return nil
+}
+
+func (s *teardownStore) MarkApplicationDeleted(context.Context, primitive.ObjectID) error {
+	s.deleted = true
+	return nil
+}
+
+func (s *teardownStore) FailActiveJobs(context.Context, primitive.ObjectID) error { return nil }
+
+func TestDecommissionDeletesLabelledApplications(t *testing.T) {
+	ctx := context.Background()
+
+	// staging 首次发布仍在进行中，没有记录到 Environments
+	dev := newApplication("demo-dev", "m1")
+	staging := newApplication("demo-staging", "m2")
+	other := newApplication("other-dev", "m3")
+	dev.Labels = map[string]string{model.ApplicationLabel: "demo"}
+	staging.Labels = map[string]string{model.ApplicationLabel: "demo"}
+	other.Labels = map[string]string{model.ApplicationLabel: "other"}
+	h := argotest.New(dev, staging, other)
+
+	app := &model.Application{Name: "demo", Environments: map[string]*model.EnvironmentState{"dev": {}}}
+	store := &teardownStore{}
+	if err := argo.Decommission(ctx, store, app, argo.DeleteOptions{}, zap.NewNop()); err == nil {
+		t.Fatal("decommission without a wait timeout succeeded")
+	}
+
+	if err := argo.Decommission(ctx, store, app, argo.DeleteOptions{Wait: 5 * time.Second}, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	list, err := h.Argo.ArgoprojV1alpha1().Applications(argo.Namespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "other-dev" {
+		t.Errorf("remaining applications = %v, want only other-dev", list.Items)
+	}
+	if !store.deleted || store.status != model.ApplicationDeleting {
+		t.Errorf("store = %+v, want marked deleted after deleting", store)
+	}
+}
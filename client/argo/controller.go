@@ -0,0 +1,250 @@
+package argo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argoinformers "github.com/argoproj/argo-cd/v3/pkg/client/informers/externalversions"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/bsonger/devflow-common/client/mongo"
+	"github.com/bsonger/devflow-common/model"
+)
+
+// JobStore Job / Application 状态的持久化
+type JobStore interface {
+	GetJob(ctx context.Context, id primitive.ObjectID) (*model.Job, error)
+	UpdateJobStatus(ctx context.Context, job *model.Job) error
+	UpdateApplicationStatus(ctx context.Context, id primitive.ObjectID, status string) error
+	// RecordDeployment 记录 Job 成功后在目标环境生效的 manifest
+	RecordDeployment(ctx context.Context, job *model.Job) error
+}
+
+// MongoJobStore 基于 mongo.Repo 的 JobStore
+type MongoJobStore struct{}
+
+func (MongoJobStore) GetJob(ctx context.Context, id primitive.ObjectID) (*model.Job, error) {
+	job := &model.Job{}
+	if err := mongo.Repo.FindByID(ctx, job, id); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (MongoJobStore) UpdateJobStatus(ctx context.Context, job *model.Job) error {
+	job.WithUpdateDefault()
+	return mongo.Repo.UpdateByID(ctx, job, job.ID, bson.M{"$set": bson.M{
+		"status":        job.Status,
+		"status_reason": job.StatusReason,
+		"applied_at":    job.AppliedAt,
+		"updated_at":    job.UpdatedAt,
+	}})
+}
+
+func (MongoJobStore) UpdateApplicationStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	return mongo.Repo.UpdateByID(ctx, &model.Application{}, id, bson.M{"$set": bson.M{
+		"status":     status,
+		"updated_at": time.Now(),
+	}})
+}
+
+func (MongoJobStore) RecordDeployment(ctx context.Context, job *model.Job) error {
+	now := time.Now()
+	set := bson.M{"updated_at": now}
+	if job.Env == "" {
+		set["active_manifest_id"] = job.ManifestID
+		set["active_manifest_name"] = job.ManifestName
+	} else {
+		set["environments."+job.Env] = &model.EnvironmentState{
+			ManifestID:   job.ManifestID,
+			ManifestName: job.ManifestName,
+			JobID:        job.ID,
+			DeployedAt:   now,
+		}
+	}
+	return mongo.Repo.UpdateByID(ctx, &model.Application{}, job.ApplicationId, bson.M{"$set": set})
+}
+
+// JobController 监听带 job_id label 的 ArgoCD Application，
+// 根据 sync / health 推进 Job 与 Application 的状态
+type JobController struct {
+	store      JobStore
+	logger     *zap.Logger
+	factory    argoinformers.SharedInformerFactory
+	informer   cache.SharedIndexInformer
+	rollbacker *Rollbacker
+}
+
+func NewJobController(store JobStore, resync time.Duration, logger *zap.Logger) *JobController {
+	if store == nil {
+		store = MongoJobStore{}
+	}
+	factory := argoinformers.NewSharedInformerFactoryWithOptions(ArgoCdClient, resync,
+		argoinformers.WithNamespace(Namespace()),
+		argoinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = model.JobIDLabel
+		}),
+	)
+	return &JobController{
+		store:    store,
+		logger:   logger,
+		factory:  factory,
+		informer: factory.Argoproj().V1alpha1().Applications().Informer(),
+	}
+}
+
+// EnableAutoRollback Upgrade Job 失败时自动回滚到上一个成功的 manifest
+func (c *JobController) EnableAutoRollback(r *Rollbacker) {
+	c.rollbacker = r
+}
+
+// Run 启动 informer 并阻塞直到 ctx 结束；ctx 结束（包括缓存同步完成前）时返回 nil
+func (c *JobController) Run(ctx context.Context) error {
+	handle := func(obj interface{}) {
+		app, ok := obj.(*appv1.Application)
+		if !ok {
+			return
+		}
+		if err := c.Sync(ctx, app); err != nil {
+			c.logger.Warn("job sync failed", zap.String("application", app.Name), zap.Error(err))
+		}
+	}
+	if _, err := c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    handle,
+		UpdateFunc: func(_, newObj interface{}) { handle(newObj) },
+	}); err != nil {
+		return err
+	}
+
+	c.factory.Start(ctx.Done())
+	defer c.factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("argo application informer cache not synced")
+	}
+	c.logger.Info("argo job controller started")
+
+	<-ctx.Done()
+	return nil
+}
+
+// Sync 根据 Application 当前状态推进对应 Job，可以单独调用
+func (c *JobController) Sync(ctx context.Context, app *appv1.Application) error {
+	jobID, err := primitive.ObjectIDFromHex(app.Labels[model.JobIDLabel])
+	if err != nil {
+		return fmt.Errorf("invalid %s label: %w", model.JobIDLabel, err)
+	}
+
+	job, err := c.store.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status.IsTerminal() {
+		return nil
+	}
+
+	since := job.CreatedAt
+	if job.AppliedAt != nil {
+		since = *job.AppliedAt
+	}
+	result := evaluate(app, operationMatch{Since: since, JobID: job.ID.Hex()})
+	next := jobStatusFor(job, result)
+	if next == job.Status {
+		return nil
+	}
+
+	prev := job.Status
+	if err := job.TransitionTo(next); err != nil {
+		c.logger.Debug("skip job transition", zap.Error(err))
+		return nil
+	}
+	if err := c.store.UpdateJobStatus(ctx, job); err != nil {
+		return err
+	}
+	c.logger.Info("job status changed",
+		zap.String("job_id", job.ID.Hex()),
+		zap.String("application", job.ApplicationName),
+		zap.String("from", string(prev)),
+		zap.String("to", string(next)),
+		zap.String("message", result.Message),
+	)
+
+	if status := applicationStatusFor(result); status != "" && !job.ApplicationId.IsZero() {
+		if err := c.store.UpdateApplicationStatus(ctx, job.ApplicationId, status); err != nil {
+			return err
+		}
+	}
+
+	if (next == model.JobSucceeded || next == model.JobRolledBack) && !job.ApplicationId.IsZero() {
+		if err := c.store.RecordDeployment(ctx, job); err != nil {
+			return err
+		}
+	}
+	if job.RollbackFrom != nil && (next == model.JobRolledBack || next == model.JobFailed) {
+		if err := c.finishRollback(ctx, *job.RollbackFrom, next == model.JobRolledBack); err != nil {
+			return err
+		}
+	}
+	if next == model.JobFailed && c.rollbacker != nil {
+		if _, err := c.rollbacker.RollbackOnFailure(ctx, job); err != nil {
+			return fmt.Errorf("auto rollback: %w", err)
+		}
+	}
+	return nil
+}
+
+// finishRollback 回滚结束后更新被回滚的原 Job
+func (c *JobController) finishRollback(ctx context.Context, id primitive.ObjectID, succeeded bool) error {
+	origin, err := c.store.GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	next := model.JobFailed
+	if succeeded {
+		next = model.JobRolledBack
+	}
+	if err := origin.TransitionTo(next); err != nil {
+		c.logger.Debug("skip job transition", zap.Error(err))
+		return nil
+	}
+	return c.store.UpdateJobStatus(ctx, origin)
+}
+
+func jobStatusFor(job *model.Job, result *WaitResult) model.JobStatus {
+	rollback := job.Type == model.JobRollback
+	switch result.Phase {
+	case WaitSucceeded:
+		if rollback {
+			return model.JobRolledBack
+		}
+		return model.JobSucceeded
+	case WaitFailed, WaitTimedOut:
+		return model.JobFailed
+	}
+	if rollback {
+		return model.JobRollingBack
+	}
+	return model.JobRunning
+}
+
+func applicationStatusFor(result *WaitResult) string {
+	switch result.Phase {
+	case WaitSucceeded:
+		return model.ApplicationRunning
+	case WaitFailed, WaitTimedOut:
+		if result.Health == health.HealthStatusDegraded {
+			return model.ApplicationDegraded
+		}
+		return model.ApplicationFailed
+	}
+	return ""
+}
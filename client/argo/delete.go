@@ -0,0 +1,185 @@
+package argo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/bsonger/devflow-common/client/mongo"
+	"github.com/bsonger/devflow-common/model"
+)
+
+// DeletePolicy 删除 Application 时如何处理它管理的资源
+type DeletePolicy string
+
+const (
+	// DeleteForeground 先删除受管资源，再删除 Application
+	DeleteForeground DeletePolicy = "foreground"
+	// DeleteBackground 先删除 Application，受管资源在后台删除
+	DeleteBackground DeletePolicy = "background"
+	// DeleteOrphan 只删除 Application，保留受管资源
+	DeleteOrphan DeletePolicy = "orphan"
+)
+
+const (
+	resourcesFinalizer           = "resources-finalizer.argocd.argoproj.io"
+	resourcesFinalizerBackground = "resources-finalizer.argocd.argoproj.io/background"
+)
+
+// DeleteOptions DeleteApplication 的参数
+type DeleteOptions struct {
+	Policy DeletePolicy
+	// Wait 等待 Application 彻底删除的超时时间，0 表示不等待
+	Wait time.Duration
+}
+
+// DeleteApplication 删除 ArgoCD Application，通过 ArgoCD finalizer 控制是否级联删除资源
+func DeleteApplication(ctx context.Context, name string, opts DeleteOptions) error {
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
+
+	if opts.Policy == "" {
+		opts.Policy = DeleteForeground
+	}
+
+	// 按策略设置 finalizer，再删除
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		app, err := applications.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		finalizers := withDeleteFinalizer(app.Finalizers, opts.Policy)
+		if slices.Equal(finalizers, app.Finalizers) {
+			return nil
+		}
+		app.Finalizers = finalizers
+		_, err = applications.Update(ctx, app, metav1.UpdateOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("set finalizer: %w", err)
+	}
+
+	if err := applications.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if opts.Wait <= 0 {
+		return nil
+	}
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, opts.Wait, true, func(ctx context.Context) (bool, error) {
+		_, err := applications.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+func withDeleteFinalizer(finalizers []string, policy DeletePolicy) []string {
+	result := slices.DeleteFunc(slices.Clone(finalizers), func(f string) bool {
+		return strings.HasPrefix(f, resourcesFinalizer)
+	})
+	switch policy {
+	case DeleteForeground:
+		result = append(result, resourcesFinalizer)
+	case DeleteBackground:
+		result = append(result, resourcesFinalizerBackground)
+	}
+	return result
+}
+
+// TeardownStore 下线 Application 需要的持久化操作
+type TeardownStore interface {
+	UpdateApplicationStatus(ctx context.Context, id primitive.ObjectID, status string) error
+	MarkApplicationDeleted(ctx context.Context, id primitive.ObjectID) error
+	// FailActiveJobs 把未结束的 Job 标记为失败
+	FailActiveJobs(ctx context.Context, applicationID primitive.ObjectID) error
+}
+
+func (MongoJobStore) MarkApplicationDeleted(ctx context.Context, id primitive.ObjectID) error {
+	now := time.Now()
+	return mongo.Repo.UpdateByID(ctx, &model.Application{}, id, bson.M{"$set": bson.M{
+		"status":     model.ApplicationDeleted,
+		"deleted_at": now,
+		"updated_at": now,
+	}})
+}
+
+func (MongoJobStore) FailActiveJobs(ctx context.Context, applicationID primitive.ObjectID) error {
+	return mongo.Repo.UpdateMany(ctx, &model.Job{},
+		bson.M{
+			"application_id": applicationID,
+			"status":         bson.M{"$in": []model.JobStatus{model.JobPending, model.JobRunning, model.JobRollingBack}},
+		},
+		bson.M{"$set": bson.M{"status": model.JobFailed, "updated_at": time.Now()}},
+	)
+}
+
+// decommissionTargets 需要删除的 ArgoCD Application：按 ApplicationLabel 列出的所有 Application
+// （包括首次发布失败或仍在进行中的环境），再补上没有 label 的旧 Application 名称
+func decommissionTargets(ctx context.Context, app *model.Application) ([]string, error) {
+	list, err := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: model.ApplicationLabel + "=" + app.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list argo applications of %s: %w", app.Name, err)
+	}
+	names := []string{app.Name}
+	for env := range app.Environments {
+		names = append(names, model.ArgoApplicationName(app.Name, env))
+	}
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// Decommission 下线 devflow Application：删除所有环境的 ArgoCD Application 并更新 Application / Job 状态
+// 只有等到 ArgoCD Application 彻底删除后才会标记为 Deleted，因此 opts.Wait 必须大于 0
+func Decommission(ctx context.Context, store TeardownStore, app *model.Application, opts DeleteOptions, logger *zap.Logger) error {
+	if opts.Wait <= 0 {
+		return fmt.Errorf("decommission %s: wait timeout must be positive", app.Name)
+	}
+	if store == nil {
+		store = MongoJobStore{}
+	}
+
+	if err := store.UpdateApplicationStatus(ctx, app.ID, model.ApplicationDeleting); err != nil {
+		return err
+	}
+	names, err := decommissionTargets(ctx, app)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := DeleteApplication(ctx, name, opts); err != nil {
+			return fmt.Errorf("delete argo application %s: %w", name, err)
+		}
+	}
+	if err := store.FailActiveJobs(ctx, app.ID); err != nil {
+		return err
+	}
+	if err := store.MarkApplicationDeleted(ctx, app.ID); err != nil {
+		return err
+	}
+
+	logger.Info("application decommissioned",
+		zap.String("application", app.Name),
+		zap.String("policy", string(opts.Policy)),
+	)
+	return nil
+}
@@ -0,0 +1,123 @@
+package argo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/bsonger/devflow-common/client/mongo"
+	"github.com/bsonger/devflow-common/model"
+)
+
+// DeployStore 发布 Job 需要的持久化操作
+type DeployStore interface {
+	JobStore
+	// PendingJobs 返回所有等待发布的 Job
+	PendingJobs(ctx context.Context) ([]*model.Job, error)
+}
+
+func (MongoJobStore) PendingJobs(ctx context.Context) ([]*model.Job, error) {
+	var jobs []*model.Job
+	filter := bson.M{
+		"status": model.JobPending,
+		"type":   bson.M{"$in": []string{model.JobInstall, model.JobUpgrade}},
+	}
+	if err := mongo.Repo.List(ctx, &model.Job{}, filter, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// DeployJob 检查发布窗口后创建或更新 Job 对应的 ArgoCD Application
+// 不在发布窗口内时 Job 保持 Pending 并记录原因，返回 false
+// 创建 Application 失败时，只有 Invalid / Forbidden 等重试也无法成功的错误才把 Job 标记为 Failed，
+// 其它错误（超时、冲突重试耗尽等）Job 保持 Pending 并记录原因，由 RunPendingJobs 重试
+func DeployJob(ctx context.Context, store JobStore, job *model.Job) (bool, error) {
+	if store == nil {
+		store = MongoJobStore{}
+	}
+
+	if !job.WindowOverride {
+		decision, err := model.GetReleaseConfig().CheckWindow(job.ProjectName, job.TargetEnv(), time.Now())
+		if err != nil {
+			return false, err
+		}
+		if !decision.Allowed {
+			if job.StatusReason != decision.Reason {
+				job.StatusReason = decision.Reason
+				if err := store.UpdateJobStatus(ctx, job); err != nil {
+					return false, err
+				}
+			}
+			return false, nil
+		}
+	}
+
+	now := time.Now()
+	job.AppliedAt = &now
+	if _, err := ApplyApplication(ctx, job.GenerateApplication()); err != nil {
+		job.StatusReason = err.Error()
+		if permanentApplyError(err) && job.TransitionTo(model.JobFailed) != nil {
+			return false, fmt.Errorf("apply application: %w", err)
+		}
+		_ = store.UpdateJobStatus(ctx, job)
+		return false, fmt.Errorf("apply application: %w", err)
+	}
+
+	job.StatusReason = ""
+	next := model.JobRunning
+	if job.Type == model.JobRollback {
+		next = model.JobRollingBack
+	}
+	if err := job.TransitionTo(next); err != nil {
+		return true, err
+	}
+	return true, store.UpdateJobStatus(ctx, job)
+}
+
+// permanentApplyError 重试也无法成功的错误
+func permanentApplyError(err error) bool {
+	return apierrors.IsInvalid(err) || apierrors.IsForbidden(err) || apierrors.IsBadRequest(err)
+}
+
+// DefaultPendingJobInterval RunPendingJobs 的 interval 不大于 0 时使用的重试间隔
+const DefaultPendingJobInterval = time.Minute
+
+// RunPendingJobs 周期性地重试因发布窗口而 Pending 的 Job，直到 ctx 结束
+// interval 不大于 0 时使用 DefaultPendingJobInterval
+func RunPendingJobs(ctx context.Context, store DeployStore, interval time.Duration, logger *zap.Logger) {
+	if store == nil {
+		store = MongoJobStore{}
+	}
+	if interval <= 0 {
+		interval = DefaultPendingJobInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		jobs, err := store.PendingJobs(ctx)
+		if err != nil {
+			logger.Error("list pending jobs failed", zap.Error(err))
+		}
+		for _, job := range jobs {
+			deployed, err := DeployJob(ctx, store, job)
+			if err != nil {
+				logger.Warn("deploy pending job failed", zap.String("job_id", job.ID.Hex()), zap.Error(err))
+				continue
+			}
+			if deployed {
+				logger.Info("pending job deployed", zap.String("job_id", job.ID.Hex()), zap.String("application", job.ApplicationName))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package argo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/bsonger/devflow-common/client/mongo"
+	"github.com/bsonger/devflow-common/model"
+)
+
+// ManagedByLabel 标记由 devflow 维护的 AppProject
+const ManagedByLabel = "app.kubernetes.io/managed-by"
+
+// GenerateAppProject 根据 devflow 配置生成 project 对应的 AppProject
+func GenerateAppProject(project string, cfg *model.ArgoConfig) (*appv1.AppProject, error) {
+	pc := cfg.ProjectConfig(project)
+
+	sourceRepos := pc.SourceRepos
+	if len(sourceRepos) == 0 {
+		repo := model.LookupConfigRepo()
+		if repo == nil || repo.Address == "" {
+			return nil, fmt.Errorf("project %s: no source_repos configured and config repo not initialized", project)
+		}
+		sourceRepos = []string{repo.Address}
+	}
+	destinations := projectDestinations(project, cfg, pc.Namespaces)
+
+	var whitelist []metav1.GroupKind
+	for _, gk := range pc.ClusterResourceWhitelist {
+		group, kind, ok := strings.Cut(gk, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid cluster resource %q, expected group/kind", gk)
+		}
+		whitelist = append(whitelist, metav1.GroupKind{Group: group, Kind: kind})
+	}
+
+	var windows appv1.SyncWindows
+	for _, w := range pc.SyncWindows {
+		if w.Kind != "allow" && w.Kind != "deny" {
+			return nil, fmt.Errorf("invalid sync window kind %q", w.Kind)
+		}
+		windows = append(windows, &appv1.SyncWindow{
+			Kind:         w.Kind,
+			Schedule:     w.Schedule,
+			Duration:     w.Duration,
+			Applications: w.Applications,
+			Namespaces:   w.Namespaces,
+			Clusters:     w.Clusters,
+			ManualSync:   w.ManualSync,
+			TimeZone:     w.TimeZone,
+		})
+	}
+
+	return &appv1.AppProject{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "AppProject",
+			APIVersion: "argoproj.io/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      project,
+			Namespace: Namespace(),
+			Labels: map[string]string{
+				ManagedByLabel: "devflow",
+			},
+		},
+		Spec: appv1.AppProjectSpec{
+			Description:              "managed by devflow",
+			SourceRepos:              sourceRepos,
+			Destinations:             destinations,
+			ClusterResourceWhitelist: whitelist,
+			SyncWindows:              windows,
+		},
+	}, nil
+}
+
+// projectDestinations 所有可能的部署目标（默认、每个环境、每个 application 覆盖），去重
+// namespace 与 Job.GenerateApplication 使用同一规则（ArgoTarget.TargetNamespace），
+// project 配置了 namespaces 时改为允许这些 namespace
+func projectDestinations(project string, cfg *model.ArgoConfig, namespaces []string) []appv1.ApplicationDestination {
+	// "" 表示未指定环境的旧 Job
+	envs := []string{""}
+	for env := range cfg.Environments {
+		envs = append(envs, env)
+	}
+	envs = append(envs, model.PromotionOrder()...)
+	apps := []string{""}
+	for app := range cfg.Applications {
+		apps = append(apps, app)
+	}
+	sort.Strings(envs)
+	sort.Strings(apps)
+
+	seen := map[string]bool{}
+	var destinations []appv1.ApplicationDestination
+	add := func(t model.ArgoTarget, ns string) {
+		key := t.Server + "|" + t.Cluster + "|" + ns
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		destinations = append(destinations, appv1.ApplicationDestination{
+			Server:    t.Server,
+			Name:      t.Cluster,
+			Namespace: ns,
+		})
+	}
+
+	for _, app := range apps {
+		for _, env := range envs {
+			target := cfg.Resolve(app, env)
+			if len(namespaces) == 0 {
+				add(target, target.TargetNamespace(project, env))
+				continue
+			}
+			for _, ns := range namespaces {
+				add(target, ns)
+			}
+		}
+	}
+	return destinations
+}
+
+// ApplyAppProject 创建或更新 AppProject，保留在 ArgoCD 中手动维护的 roles 和 signature keys
+func ApplyAppProject(ctx context.Context, project *appv1.AppProject) (bool, error) {
+	projects := ArgoCdClient.ArgoprojV1alpha1().AppProjects(Namespace())
+
+	changed := false
+	err := retry.OnError(retry.DefaultBackoff, isRetryable, func() error {
+		current, err := projects.Get(ctx, project.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if _, err := projects.Create(ctx, project, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			changed = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		spec := project.Spec.DeepCopy()
+		spec.Roles = current.Spec.Roles
+		spec.SignatureKeys = current.Spec.SignatureKeys
+		labels := mergeOwned(current.Labels, project.Labels)
+		if equality.Semantic.DeepEqual(current.Spec, *spec) && equality.Semantic.DeepEqual(current.Labels, labels) {
+			changed = false
+			return nil
+		}
+
+		current.Spec = *spec
+		current.Labels = labels
+		if _, err := projects.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// ReconcileProjects 为每个 devflow project 创建或更新 AppProject
+// 未开启 argo.manage_projects 时 Application 使用默认 project，不做任何处理
+func ReconcileProjects(ctx context.Context, projects []string, logger *zap.Logger) error {
+	cfg := model.GetArgoConfig()
+	if !cfg.ManageProjects {
+		return nil
+	}
+
+	var errs []string
+	for _, name := range projects {
+		project, err := GenerateAppProject(name, cfg)
+		if err == nil {
+			var changed bool
+			changed, err = ApplyAppProject(ctx, project)
+			if changed {
+				logger.Info("argo app project reconciled", zap.String("project", name))
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("reconcile app projects: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DevflowProjects 返回所有 Application 使用的 project
+func DevflowProjects(ctx context.Context) ([]string, error) {
+	var apps []model.Application
+	if err := mongo.Repo.List(ctx, &model.Application{}, bson.M{"deleted_at": bson.M{"$exists": false}}, &apps); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var projects []string
+	for _, app := range apps {
+		if app.ProjectName != "" && !seen[app.ProjectName] {
+			seen[app.ProjectName] = true
+			projects = append(projects, app.ProjectName)
+		}
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// DefaultProjectReconcileInterval RunProjectReconciler 的 interval 不大于 0 时使用的同步间隔
+const DefaultProjectReconcileInterval = 5 * time.Minute
+
+// RunProjectReconciler 周期性地为 mongo 中所有 project 同步 AppProject，直到 ctx 结束
+// interval 不大于 0 时使用 DefaultProjectReconcileInterval；未开启 argo.manage_projects 时跳过本轮
+func RunProjectReconciler(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	if interval <= 0 {
+		interval = DefaultProjectReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if model.GetArgoConfig().ManageProjects {
+			projects, err := DevflowProjects(ctx)
+			if err == nil {
+				err = ReconcileProjects(ctx, projects, logger)
+			}
+			if err != nil {
+				logger.Error("app project reconcile failed", zap.Error(err))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package argo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+// Promote 把 application 在 from 环境生效的 manifest 发布到 to 环境
+// to 为空时使用晋级顺序中的下一个环境，返回新建的发布 Job
+// 不在发布窗口内时 Job 保持 Pending，由 RunPendingJobs 在窗口打开后发布
+func Promote(ctx context.Context, store RollbackStore, applicationID primitive.ObjectID, from, to string, logger *zap.Logger) (*model.Job, error) {
+	if store == nil {
+		store = MongoJobStore{}
+	}
+	if to == "" {
+		to = model.NextEnvironment(from)
+		if to == "" {
+			return nil, fmt.Errorf("environment %s has no next environment", from)
+		}
+	}
+	if err := model.ValidatePromotion(from, to); err != nil {
+		return nil, err
+	}
+
+	app, err := store.GetApplication(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("get application: %w", err)
+	}
+	source := app.Environments[from]
+	if source == nil {
+		return nil, fmt.Errorf("application %s has no successful deployment in %s", app.Name, from)
+	}
+
+	jobType := model.JobInstall
+	if target := app.Environments[to]; target != nil {
+		if target.ManifestID == source.ManifestID {
+			return nil, fmt.Errorf("manifest %s is already active in %s", source.ManifestName, to)
+		}
+		jobType = model.JobUpgrade
+	}
+
+	job := &model.Job{
+		ApplicationId:   app.ID,
+		ApplicationName: app.Name,
+		ProjectName:     app.ProjectName,
+		ManifestID:      source.ManifestID,
+		ManifestName:    source.ManifestName,
+		Type:            jobType,
+		Status:          model.JobPending,
+		Env:             to,
+	}
+	if err := store.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("create job: %w", err)
+	}
+
+	deployed, err := DeployJob(ctx, store, job)
+	if err != nil {
+		return job, err
+	}
+	if !deployed {
+		logger.Info("promotion pending", zap.String("job_id", job.ID.Hex()), zap.String("reason", job.StatusReason))
+		return job, nil
+	}
+
+	logger.Info("manifest promoted",
+		zap.String("application", app.Name),
+		zap.String("manifest", source.ManifestName),
+		zap.String("from", from),
+		zap.String("to", to),
+		zap.String("job_id", job.ID.Hex()),
+	)
+	return job, nil
+}
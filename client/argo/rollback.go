@@ -0,0 +1,188 @@
+package argo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/bsonger/devflow-common/client/mongo"
+	"github.com/bsonger/devflow-common/model"
+)
+
+var ErrNoRollbackTarget = errors.New("no previous successful manifest to roll back to")
+
+// RollbackStore 回滚需要的持久化操作
+type RollbackStore interface {
+	JobStore
+	GetApplication(ctx context.Context, id primitive.ObjectID) (*model.Application, error)
+	GetManifest(ctx context.Context, id primitive.ObjectID) (*model.Manifest, error)
+	// LastSucceededJob 返回 env 中最近一次成功、且 manifest 不是 exclude 的发布 Job
+	LastSucceededJob(ctx context.Context, applicationID primitive.ObjectID, env string, exclude primitive.ObjectID) (*model.Job, error)
+	CreateJob(ctx context.Context, job *model.Job) error
+}
+
+func (MongoJobStore) GetApplication(ctx context.Context, id primitive.ObjectID) (*model.Application, error) {
+	app := &model.Application{}
+	if err := mongo.Repo.FindByID(ctx, app, id); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func (MongoJobStore) GetManifest(ctx context.Context, id primitive.ObjectID) (*model.Manifest, error) {
+	m := &model.Manifest{}
+	if err := mongo.Repo.FindByID(ctx, m, id); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (MongoJobStore) LastSucceededJob(ctx context.Context, applicationID primitive.ObjectID, env string, exclude primitive.ObjectID) (*model.Job, error) {
+	var jobs []*model.Job
+	filter := bson.M{
+		"application_id": applicationID,
+		"manifest_id":    bson.M{"$ne": exclude},
+		// 被回滚的原 Job 也会标记为 RolledBack，只有 Rollback 类型的 RolledBack 才表示发布成功
+		"$or": bson.A{
+			bson.M{"status": model.JobSucceeded},
+			bson.M{"status": model.JobRolledBack, "type": model.JobRollback},
+		},
+	}
+	if env == "" {
+		filter["env"] = bson.M{"$exists": false}
+	} else {
+		filter["env"] = env
+	}
+	if err := mongo.Repo.List(ctx, &model.Job{}, filter, &jobs); err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, ErrNoRollbackTarget
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs[0], nil
+}
+
+func (MongoJobStore) CreateJob(ctx context.Context, job *model.Job) error {
+	job.WithCreateDefault()
+	return mongo.Repo.Create(ctx, job)
+}
+
+// Rollbacker 通过 ArgoCD 把 Application 回滚到之前的 Manifest
+type Rollbacker struct {
+	store  RollbackStore
+	logger *zap.Logger
+}
+
+func NewRollbacker(store RollbackStore, logger *zap.Logger) *Rollbacker {
+	if store == nil {
+		store = MongoJobStore{}
+	}
+	return &Rollbacker{store: store, logger: logger}
+}
+
+// Rollback 创建 Rollback Job，并把 env 对应的 ArgoCD Application 指向目标 manifest 后触发同步
+// manifestID 为 nil 时回滚到该环境最近一次发布成功的 manifest（不含当前生效的）
+// rollbackFrom 为触发回滚的失败 Job，可以为 nil
+func (r *Rollbacker) Rollback(ctx context.Context, applicationID primitive.ObjectID, env string, manifestID *primitive.ObjectID, rollbackFrom *model.Job) (*model.Job, error) {
+	app, err := r.store.GetApplication(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("get application: %w", err)
+	}
+
+	if manifestID == nil {
+		exclude := primitive.NilObjectID
+		if state := app.Environments[env]; env != "" && state != nil {
+			exclude = state.ManifestID
+		} else if env == "" && app.ActiveManifestID != nil {
+			exclude = *app.ActiveManifestID
+		}
+		if rollbackFrom != nil {
+			// 失败的升级不一定已经写入 ActiveManifestID
+			exclude = rollbackFrom.ManifestID
+		}
+		last, err := r.store.LastSucceededJob(ctx, applicationID, env, exclude)
+		if err != nil {
+			return nil, err
+		}
+		manifestID = &last.ManifestID
+	}
+
+	manifest, err := r.store.GetManifest(ctx, *manifestID)
+	if err != nil {
+		return nil, fmt.Errorf("get manifest: %w", err)
+	}
+
+	job := &model.Job{
+		ApplicationId:   app.ID,
+		ApplicationName: app.Name,
+		ProjectName:     app.ProjectName,
+		ManifestID:      manifest.ID,
+		ManifestName:    manifest.Name,
+		Type:            model.JobRollback,
+		Status:          model.JobPending,
+		Env:             env,
+		// 回滚用于止损，不受发布窗口限制
+		WindowOverride: true,
+	}
+	if rollbackFrom != nil {
+		job.RollbackFrom = &rollbackFrom.ID
+	}
+	if err := r.store.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("create rollback job: %w", err)
+	}
+
+	now := time.Now()
+	job.AppliedAt = &now
+	if _, err := ApplyApplication(ctx, job.GenerateApplication()); err != nil {
+		r.fail(ctx, job)
+		return job, fmt.Errorf("apply application: %w", err)
+	}
+	if err := Sync(ctx, job.ArgoApplicationName(), SyncOptions{Prune: true, JobID: job.ID.Hex()}); err != nil && !errors.Is(err, ErrOperationInProgress) {
+		r.fail(ctx, job)
+		return job, fmt.Errorf("trigger sync: %w", err)
+	}
+
+	if err := job.TransitionTo(model.JobRollingBack); err != nil {
+		return job, err
+	}
+	if err := r.store.UpdateJobStatus(ctx, job); err != nil {
+		return job, err
+	}
+	if rollbackFrom != nil && rollbackFrom.TransitionTo(model.JobRollingBack) == nil {
+		if err := r.store.UpdateJobStatus(ctx, rollbackFrom); err != nil {
+			return job, err
+		}
+	}
+
+	r.logger.Info("rollback started",
+		zap.String("application", app.Name),
+		zap.String("env", env),
+		zap.String("job_id", job.ID.Hex()),
+		zap.String("manifest", manifest.Name),
+	)
+	return job, nil
+}
+
+// RollbackOnFailure 升级失败时自动回滚，其他情况返回 nil
+func (r *Rollbacker) RollbackOnFailure(ctx context.Context, job *model.Job) (*model.Job, error) {
+	if job.Type != model.JobUpgrade || job.Status != model.JobFailed {
+		return nil, nil
+	}
+	return r.Rollback(ctx, job.ApplicationId, job.Env, nil, job)
+}
+
+func (r *Rollbacker) fail(ctx context.Context, job *model.Job) {
+	if job.TransitionTo(model.JobFailed) != nil {
+		return
+	}
+	if err := r.store.UpdateJobStatus(ctx, job); err != nil {
+		r.logger.Warn("update rollback job failed", zap.String("job_id", job.ID.Hex()), zap.Error(err))
+	}
+}
@@ -0,0 +1,157 @@
+package argo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+var ErrOperationInProgress = errors.New("another operation is already in progress")
+
+// Refresh 请求 ArgoCD 重新比对 Application，hard 会忽略 manifest 缓存
+func Refresh(ctx context.Context, name string, refreshType appv1.RefreshType) error {
+	if refreshType == "" {
+		refreshType = appv1.RefreshTypeNormal
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				appv1.AnnotationKeyRefresh: string(refreshType),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
+	_, err = applications.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// SyncOptions 一次手动同步的参数
+type SyncOptions struct {
+	Revision string
+	Prune    bool
+	DryRun   bool
+	// Force 使用 kubectl apply --force
+	Force bool
+	// Resources 只同步指定资源，为空时同步全部
+	Resources []appv1.SyncOperationResource
+	// SyncOptions ArgoCD sync options，例如 CreateNamespace=true
+	SyncOptions []string
+	// Retry 失败重试策略
+	Retry *appv1.RetryStrategy
+	// JobID 发起同步的 Job，写入 operation info 用于匹配 operation 与 Job
+	JobID string
+}
+
+// OperationJobInfo operation info 中记录 Job ID 的名称
+const OperationJobInfo = "devflow.io/job-id"
+
+// Sync 写入 Application 的 operation 字段发起一次同步
+// 已有未完成的 operation 时返回 ErrOperationInProgress
+func Sync(ctx context.Context, name string, opts SyncOptions) error {
+	op := &appv1.Operation{
+		Sync: &appv1.SyncOperation{
+			Revision:    opts.Revision,
+			Prune:       opts.Prune,
+			DryRun:      opts.DryRun,
+			Resources:   opts.Resources,
+			SyncOptions: opts.SyncOptions,
+		},
+		InitiatedBy: appv1.OperationInitiator{Username: "devflow"},
+	}
+	if opts.Force {
+		op.Sync.SyncStrategy = &appv1.SyncStrategy{
+			Apply: &appv1.SyncStrategyApply{Force: true},
+		}
+	}
+	if opts.JobID != "" {
+		op.Info = []*appv1.Info{{Name: OperationJobInfo, Value: opts.JobID}}
+	}
+	if opts.Retry != nil {
+		op.Retry = *opts.Retry
+	}
+
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		app, err := applications.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if app.Operation != nil {
+			return ErrOperationInProgress
+		}
+		app.Operation = op
+		_, err = applications.Update(ctx, app, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// OperationResult Application 最近一次 operation 的状态
+type OperationResult struct {
+	// Pending operation 已写入，ArgoCD 还没有开始处理
+	Pending    bool                      `json:"pending"`
+	Phase      synccommon.OperationPhase `json:"phase,omitempty"`
+	Message    string                    `json:"message,omitempty"`
+	Revision   string                    `json:"revision,omitempty"`
+	RetryCount int64                     `json:"retry_count,omitempty"`
+	StartedAt  *time.Time                `json:"started_at,omitempty"`
+	FinishedAt *time.Time                `json:"finished_at,omitempty"`
+}
+
+// Completed operation 是否已结束
+func (r *OperationResult) Completed() bool {
+	return !r.Pending && r.Phase.Completed()
+}
+
+// Succeeded operation 是否成功结束
+func (r *OperationResult) Succeeded() bool {
+	return !r.Pending && r.Phase.Successful() && r.Phase.Completed()
+}
+
+// GetOperation 返回 Application 当前或最近一次 operation 的状态
+func GetOperation(ctx context.Context, name string) (*OperationResult, error) {
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
+	app, err := applications.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	state := app.Status.OperationState
+	if state == nil {
+		if app.Operation != nil {
+			return &OperationResult{Pending: true}, nil
+		}
+		return nil, fmt.Errorf("application %s has no operation", name)
+	}
+
+	result := &OperationResult{
+		// operation 字段还在但 state 已结束，说明新的 operation 还没被处理
+		Pending:    app.Operation != nil && state.Phase.Completed(),
+		Phase:      state.Phase,
+		Message:    state.Message,
+		RetryCount: state.RetryCount,
+	}
+	if state.SyncResult != nil {
+		result.Revision = state.SyncResult.Revision
+	}
+	if !state.StartedAt.IsZero() {
+		t := state.StartedAt.Time
+		result.StartedAt = &t
+	}
+	if state.FinishedAt != nil {
+		t := state.FinishedAt.Time
+		result.FinishedAt = &t
+	}
+	return result, nil
+}
@@ -0,0 +1,281 @@
+package argo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+type WaitPhase string
+
+const (
+	WaitProgressing WaitPhase = "Progressing"
+	WaitSucceeded   WaitPhase = "Succeeded"
+	WaitFailed      WaitPhase = "Failed"
+	WaitTimedOut    WaitPhase = "TimedOut"
+)
+
+// WaitOptions WaitForApplication 的参数
+type WaitOptions struct {
+	// Revision 期望同步到的 revision，为空时不校验
+	Revision string
+	// Timeout 超时时间，0 表示只受 ctx 控制
+	Timeout time.Duration
+	// OnProgress 每次 Application 变化时回调，可用于上报中间状态
+	OnProgress func(*WaitResult)
+	// Since 发布（apply / sync）的时间，早于它开始的 operation 不属于本次发布，为空时使用调用时间
+	Since time.Time
+}
+
+// ResourceHealth 单个受管资源的状态
+type ResourceHealth struct {
+	Group     string                  `json:"group,omitempty"`
+	Kind      string                  `json:"kind"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Name      string                  `json:"name"`
+	Sync      appv1.SyncStatusCode    `json:"sync"`
+	Health    health.HealthStatusCode `json:"health,omitempty"`
+	Message   string                  `json:"message,omitempty"`
+}
+
+// WaitResult Application 发布结果
+type WaitResult struct {
+	Application string                    `json:"application"`
+	Phase       WaitPhase                 `json:"phase"`
+	Sync        appv1.SyncStatusCode      `json:"sync"`
+	Health      health.HealthStatusCode   `json:"health"`
+	Revision    string                    `json:"revision"`
+	Operation   synccommon.OperationPhase `json:"operation,omitempty"`
+	Message     string                    `json:"message,omitempty"`
+	Resources   []ResourceHealth          `json:"resources,omitempty"`
+}
+
+// JobStatus 把发布结果映射为 Job 状态
+func (r *WaitResult) JobStatus() model.JobStatus {
+	switch r.Phase {
+	case WaitSucceeded:
+		return model.JobSucceeded
+	case WaitFailed, WaitTimedOut:
+		return model.JobFailed
+	}
+	return model.JobRunning
+}
+
+// WaitForApplication 通过 watch 等待 Application 达到 Synced + Healthy（且 revision 匹配）
+// Degraded / Missing / operation 失败 会提前返回 WaitFailed，超时返回 WaitTimedOut
+// 只有请求本身出错时才返回 error
+func WaitForApplication(ctx context.Context, name string, opts WaitOptions) (*WaitResult, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	applications := ArgoCdClient.ArgoprojV1alpha1().Applications(Namespace())
+	match := operationMatch{Revision: opts.Revision, Since: opts.Since}
+	if match.Since.IsZero() {
+		match.Since = time.Now()
+	}
+
+	var app *appv1.Application
+	var result *WaitResult
+	// refresh 重新获取 Application 并评估，返回是否已结束
+	refresh := func() (bool, error) {
+		updated, err := applications.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if ctx.Err() != nil && result != nil {
+				result = timedOut(result)
+				return true, nil
+			}
+			return false, err
+		}
+		app = updated
+		result = evaluate(app, match)
+		if opts.OnProgress != nil {
+			opts.OnProgress(result)
+		}
+		return result.Phase != WaitProgressing, nil
+	}
+
+	if done, err := refresh(); err != nil {
+		return nil, err
+	} else if done {
+		return result, nil
+	}
+
+	for {
+		w, err := applications.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: app.ResourceVersion,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return timedOut(result), nil
+			}
+			return nil, err
+		}
+
+		done, relist := func() (bool, bool) {
+			defer w.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					result = timedOut(result)
+					return true, false
+				case event, ok := <-w.ResultChan():
+					if !ok {
+						// watch 被服务端关闭，从当前 resourceVersion 重新建立
+						return false, false
+					}
+					switch event.Type {
+					case watch.Deleted:
+						result.Phase = WaitFailed
+						result.Message = "application deleted"
+						return true, false
+					case watch.Error:
+						// 例如 410 Gone：resourceVersion 已过期，需要重新获取
+						return false, true
+					}
+
+					updated, ok := event.Object.(*appv1.Application)
+					if !ok || updated.Name != name {
+						continue
+					}
+					app = updated
+					result = evaluate(app, match)
+					if opts.OnProgress != nil {
+						opts.OnProgress(result)
+					}
+					if result.Phase != WaitProgressing {
+						return true, false
+					}
+				}
+			}
+		}()
+		if done {
+			return result, nil
+		}
+		if relist {
+			done, err := refresh()
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return result, nil
+			}
+		}
+	}
+}
+
+// operationMatch 用于判断 Application 的 operation 是否属于本次发布
+type operationMatch struct {
+	// Revision 期望同步到的 revision
+	Revision string
+	// Since 发布时间，之前开始的 operation 是历史 operation
+	Since time.Time
+	// JobID 发起 operation 的 Job，对应 operation info 中的 OperationJobInfo
+	JobID string
+}
+
+func evaluate(app *appv1.Application, match operationMatch) *WaitResult {
+	revision := match.Revision
+	result := &WaitResult{
+		Application: app.Name,
+		Phase:       WaitProgressing,
+		Sync:        app.Status.Sync.Status,
+		Health:      app.Status.Health.Status,
+		Revision:    app.Status.Sync.Revision,
+	}
+	for _, r := range app.Status.Resources {
+		rh := ResourceHealth{
+			Group:     r.Group,
+			Kind:      r.Kind,
+			Namespace: r.Namespace,
+			Name:      r.Name,
+			Sync:      r.Status,
+		}
+		if r.Health != nil {
+			rh.Health = r.Health.Status
+			rh.Message = r.Health.Message
+		}
+		result.Resources = append(result.Resources, rh)
+	}
+
+	// 本次发布触发的 operation 失败
+	if op := app.Status.OperationState; op != nil && currentOperation(op, match) {
+		result.Operation = op.Phase
+		if op.Phase == synccommon.OperationFailed || op.Phase == synccommon.OperationError {
+			result.Phase = WaitFailed
+			result.Message = fmt.Sprintf("operation %s: %s", op.Phase, op.Message)
+			return result
+		}
+	}
+
+	// spec 变化后（例如只改了 manifest-id 参数）ArgoCD 还没重新比对时，sync / health 仍是上一次的结果
+	if !reconciled(app) {
+		result.Message = "waiting for argocd to compare the current spec"
+		return result
+	}
+
+	revisionMatched := revision == "" || app.Status.Sync.Revision == revision
+	if app.Status.Sync.Status != appv1.SyncStatusCodeSynced || !revisionMatched {
+		return result
+	}
+
+	// 已同步到目标 revision，再看健康状态
+	switch app.Status.Health.Status {
+	case health.HealthStatusHealthy:
+		result.Phase = WaitSucceeded
+	case health.HealthStatusDegraded, health.HealthStatusMissing:
+		result.Phase = WaitFailed
+		result.Message = fmt.Sprintf("application %s", app.Status.Health.Status)
+		for _, r := range result.Resources {
+			if r.Health == health.HealthStatusDegraded || r.Health == health.HealthStatusMissing {
+				result.Message += fmt.Sprintf("; %s/%s %s: %s", r.Kind, r.Name, r.Health, r.Message)
+			}
+		}
+	}
+	return result
+}
+
+// reconciled ArgoCD 最近一次比对使用的 source（包括 plugin 参数）是否就是当前 spec
+func reconciled(app *appv1.Application) bool {
+	compared := app.Status.Sync.ComparedTo
+	if app.Spec.HasMultipleSources() {
+		return equality.Semantic.DeepEqual(compared.Sources, app.Spec.Sources)
+	}
+	return equality.Semantic.DeepEqual(compared.Source, app.Spec.GetSource())
+}
+
+// currentOperation 判断 operationState 是否属于本次发布，避免被历史失败干扰
+// 优先按 Sync 写入的 Job 信息匹配，其次按 revision，最后要求 operation 在发布之后开始
+func currentOperation(op *appv1.OperationState, match operationMatch) bool {
+	if match.JobID != "" {
+		for _, info := range op.Operation.Info {
+			if info != nil && info.Name == OperationJobInfo {
+				return info.Value == match.JobID
+			}
+		}
+	}
+	if match.Revision != "" && op.SyncResult != nil {
+		return op.SyncResult.Revision == match.Revision
+	}
+	// metav1.Time 只精确到秒
+	return !op.StartedAt.Time.Before(match.Since.Truncate(time.Second))
+}
+
+func timedOut(last *WaitResult) *WaitResult {
+	last.Phase = WaitTimedOut
+	last.Message = fmt.Sprintf("timed out waiting for application (sync=%s, health=%s)", last.Sync, last.Health)
+	return last
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envDocument 按 mapstructure 名称把环境变量转换成与配置文件相同结构的文档
+// 例如 DEVFLOW_MONGO_URI -> mongo.uri；slice 用逗号分隔；map 类型字段不支持环境变量
+func envDocument(prefix string, t reflect.Type, lookup func(string) (string, bool)) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// 内嵌（squash）字段与父结构同级
+		if ft.Kind() == reflect.Struct && (f.Anonymous || strings.Contains(opts, "squash")) {
+			sub, err := envDocument(prefix, ft, lookup)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range sub {
+				doc[k] = v
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		key := yamlName(f, name)
+		envName := strings.ToUpper(prefix + "_" + name)
+
+		if ft.Kind() == reflect.Struct {
+			sub, err := envDocument(envName, ft, lookup)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) > 0 {
+				doc[key] = sub
+			}
+			continue
+		}
+
+		raw, ok := lookup(envName)
+		if !ok {
+			continue
+		}
+		v, err := parseEnv(ft, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", envName, err)
+		}
+		if v != nil {
+			doc[key] = v
+		}
+	}
+	return doc, nil
+}
+
+func parseEnv(t reflect.Type, raw string) (interface{}, error) {
+	if t == durationType {
+		if _, err := time.ParseDuration(raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(raw, 10, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, t.Bits())
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported slice type %s", t)
+		}
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+	// map 等类型只能通过文件或 Consul 设置
+	return nil, nil
+}
+
+// yamlName 文档使用 yaml 名称，缺省时与 mapstructure 名称一致
+func yamlName(f reflect.StructField, fallback string) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); name != "" {
+		return name
+	}
+	return fallback
+}
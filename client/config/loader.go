@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bsonger/devflow-common/client/consul"
+	"github.com/bsonger/devflow-common/model"
+)
+
+// Options 分层加载选项，优先级从低到高：Defaults < File < 环境变量 < Consul
+type Options struct {
+	// Defaults 最底层配置，nil 时使用 model.DefaultConfig()
+	Defaults *model.Config
+	// File YAML 或 JSON 配置文件路径，为空时跳过
+	File string
+	// EnvPrefix 环境变量前缀，例如 DEVFLOW 对应 DEVFLOW_SERVER_PORT、DEVFLOW_OTEL_SERVICE_NAME，为空时跳过
+	EnvPrefix string
+	// Consul 为 true 时按已合并配置中的 consul 段读取 key 并合并
+	Consul bool
+}
+
+// Result 加载结果，Provenance 记录每个字段来自哪一层
+type Result struct {
+	Config     *model.Config
+	Provenance Provenance
+}
+
+// Load 按优先级逐层合并配置，每层只覆盖自己出现的字段（合并策略见 consul.MergeYAML）
+// 校验通过后通过 model.SetConfig 设置为全局配置（同时初始化 model.C）
+func Load(opts Options) (*Result, error) {
+	defaults := opts.Defaults
+	if defaults == nil {
+		defaults = model.DefaultConfig()
+	}
+	cfg := defaults.Clone()
+	prov := Provenance{}
+	if err := prov.recordConfig(SourceDefault, cfg); err != nil {
+		return nil, err
+	}
+
+	if opts.File != "" {
+		data, err := os.ReadFile(opts.File)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		// JSON 是 YAML 的子集，统一按 YAML 解析
+		if err := mergeLayer(cfg, prov, SourceFile, data); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", opts.File, err)
+		}
+	}
+
+	if opts.EnvPrefix != "" {
+		doc, err := envDocument(opts.EnvPrefix, reflect.TypeOf(model.Config{}), os.LookupEnv)
+		if err != nil {
+			return nil, fmt.Errorf("config env: %w", err)
+		}
+		if len(doc) > 0 {
+			data, err := yaml.Marshal(doc)
+			if err != nil {
+				return nil, fmt.Errorf("config env: %w", err)
+			}
+			if err := mergeLayer(cfg, prov, SourceEnv, data); err != nil {
+				return nil, fmt.Errorf("config env: %w", err)
+			}
+		}
+	}
+
+	if opts.Consul && cfg.Consul != nil && cfg.Consul.Address != "" {
+		if consul.ConsulClient == nil {
+			if err := consul.InitConsulClient(cfg.Consul); err != nil {
+				return nil, fmt.Errorf("init consul client: %w", err)
+			}
+		}
+		data, err := consul.ReadConfig(cfg.Consul)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			if err := mergeLayer(cfg, prov, SourceConsul, data); err != nil {
+				return nil, fmt.Errorf("consul config: %w", err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	model.SetConfig(cfg)
+	return &Result{Config: cfg, Provenance: prov}, nil
+}
+
+func mergeLayer(cfg *model.Config, prov Provenance, source Source, data []byte) error {
+	if err := consul.MergeYAML(cfg, data); err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	prov.record(source, doc)
+	return nil
+}
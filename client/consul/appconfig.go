@@ -0,0 +1,254 @@
+package consul
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+// 应用运行时配置在 Consul KV 中的布局：
+//
+//	<prefix>/<application>/<env>/current/<file>        当前生效的文件，应用监听这个前缀
+//	<prefix>/<application>/<env>/meta                  当前版本信息（ConfigVersion JSON）
+//	<prefix>/<application>/<env>/history/<n>/meta      历史版本信息
+//	<prefix>/<application>/<env>/history/<n>/files/<file>
+const DefaultAppConfigPrefix = "devflow/apps"
+
+// keepConfigVersions 每个 application/env 保留的历史版本数
+const keepConfigVersions = 20
+
+// maxConfigFiles 一次发布最多的文件数：每个文件 2 个 op，另有最多 4 个固定 op，
+// 不超过 Consul 单个事务 64 个 op 的限制
+const maxConfigFiles = 30
+
+// ErrConfigVersionConflict 发布时当前版本已被其他人更新
+var ErrConfigVersionConflict = errors.New("config version changed concurrently")
+
+// ConfigVersion 一次发布的版本信息
+type ConfigVersion struct {
+	Version           int       `json:"version"`
+	ConfigurationID   string    `json:"configuration_id"`
+	ConfigurationName string    `json:"configuration_name"`
+	Files             []string  `json:"files"`
+	Checksum          string    `json:"checksum"`
+	PublishedAt       time.Time `json:"published_at"`
+	// RollbackOf 回滚时为回滚到的版本号
+	RollbackOf int `json:"rollback_of,omitempty"`
+}
+
+// AppConfigPrefix 返回 application 在 env 下的 KV 前缀，env 为空时使用当前环境
+func AppConfigPrefix(application, env string) string {
+	if env == "" {
+		env = model.Environment()
+	}
+	return path.Join(DefaultAppConfigPrefix, application, env)
+}
+
+// PublishConfiguration 把 Configuration 的文件发布到 application/env 的 current 前缀并生成新版本
+// 内容与当前版本一致时不生成新版本，直接返回当前版本
+func PublishConfiguration(ctx context.Context, application, env string, cfg *model.Configuration) (*ConfigVersion, error) {
+	files := map[string]string{}
+	for _, f := range cfg.Files {
+		if err := validFileName(f.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := files[f.Name]; ok {
+			return nil, fmt.Errorf("configuration %s has duplicate file %s", cfg.Name, f.Name)
+		}
+		files[f.Name] = f.Content
+	}
+	return publish(ctx, AppConfigPrefix(application, env), files, ConfigVersion{
+		ConfigurationID:   cfg.ID.Hex(),
+		ConfigurationName: cfg.Name,
+	})
+}
+
+// RollbackConfiguration 把 version 的文件重新发布为新版本
+func RollbackConfiguration(ctx context.Context, application, env string, version int) (*ConfigVersion, error) {
+	prefix := AppConfigPrefix(application, env)
+	target, files, err := readVersion(ctx, prefix, version)
+	if err != nil {
+		return nil, err
+	}
+	return publish(ctx, prefix, files, ConfigVersion{
+		ConfigurationID:   target.ConfigurationID,
+		ConfigurationName: target.ConfigurationName,
+		RollbackOf:        version,
+	})
+}
+
+// CurrentConfigVersion 返回当前版本，尚未发布时返回 nil
+func CurrentConfigVersion(ctx context.Context, application, env string) (*ConfigVersion, error) {
+	if ConsulClient == nil {
+		return nil, fmt.Errorf("consul client not initialized")
+	}
+	current, _, err := readMeta(ctx, AppConfigPrefix(application, env)+"/meta")
+	return current, err
+}
+
+// ConfigVersions 返回保留的历史版本，按版本号从新到旧
+func ConfigVersions(ctx context.Context, application, env string) ([]ConfigVersion, error) {
+	if ConsulClient == nil {
+		return nil, fmt.Errorf("consul client not initialized")
+	}
+	prefix := AppConfigPrefix(application, env) + "/history/"
+	pairs, _, err := ConsulClient.KV().List(prefix, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("list config versions: %w", err)
+	}
+
+	var versions []ConfigVersion
+	for _, p := range pairs {
+		// 只取 history/<n>/meta，排除 history/<n>/files/ 下同名的文件
+		parts := strings.Split(strings.TrimPrefix(p.Key, prefix), "/")
+		if len(parts) != 2 || parts[1] != "meta" {
+			continue
+		}
+		var v ConfigVersion
+		if err := json.Unmarshal(p.Value, &v); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", p.Key, err)
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, nil
+}
+
+func publish(ctx context.Context, prefix string, files map[string]string, next ConfigVersion) (*ConfigVersion, error) {
+	if ConsulClient == nil {
+		return nil, fmt.Errorf("consul client not initialized")
+	}
+	if len(files) > maxConfigFiles {
+		return nil, fmt.Errorf("configuration %s has %d files, at most %d can be published", next.ConfigurationName, len(files), maxConfigFiles)
+	}
+
+	current, metaIndex, err := readMeta(ctx, prefix+"/meta")
+	if err != nil {
+		return nil, err
+	}
+	checksum := checksumFiles(files)
+	if current != nil && current.Checksum == checksum && next.RollbackOf == 0 {
+		return current, nil
+	}
+
+	next.Version = 1
+	if current != nil {
+		next.Version = current.Version + 1
+	}
+	next.Checksum = checksum
+	next.PublishedAt = time.Now().UTC()
+	for name := range files {
+		next.Files = append(next.Files, name)
+	}
+	sort.Strings(next.Files)
+
+	meta, err := json.Marshal(next)
+	if err != nil {
+		return nil, err
+	}
+	history := fmt.Sprintf("%s/history/%d", prefix, next.Version)
+
+	// meta 使用 CAS 保证并发发布时版本号不会重复；metaIndex 为 0 表示 key 必须不存在
+	ops := api.TxnOps{
+		{KV: &api.KVTxnOp{Verb: api.KVCAS, Key: prefix + "/meta", Value: meta, Index: metaIndex}},
+		{KV: &api.KVTxnOp{Verb: api.KVDeleteTree, Key: prefix + "/current/"}},
+		{KV: &api.KVTxnOp{Verb: api.KVSet, Key: history + "/meta", Value: meta}},
+	}
+	for _, name := range next.Files {
+		ops = append(ops,
+			&api.TxnOp{KV: &api.KVTxnOp{Verb: api.KVSet, Key: prefix + "/current/" + name, Value: []byte(files[name])}},
+			&api.TxnOp{KV: &api.KVTxnOp{Verb: api.KVSet, Key: history + "/files/" + name, Value: []byte(files[name])}},
+		)
+	}
+	if old := next.Version - keepConfigVersions; old > 0 {
+		ops = append(ops, &api.TxnOp{KV: &api.KVTxnOp{Verb: api.KVDeleteTree, Key: fmt.Sprintf("%s/history/%d/", prefix, old)}})
+	}
+
+	ok, resp, _, err := ConsulClient.Txn().Txn(ops, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("publish config %s: %w", prefix, err)
+	}
+	if !ok {
+		if len(resp.Errors) > 0 && resp.Errors[0].OpIndex == 0 {
+			return nil, fmt.Errorf("publish config %s: %w", prefix, ErrConfigVersionConflict)
+		}
+		var msgs []string
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.What)
+		}
+		return nil, fmt.Errorf("publish config %s: %s", prefix, strings.Join(msgs, "; "))
+	}
+	return &next, nil
+}
+
+func readMeta(ctx context.Context, key string) (*ConfigVersion, uint64, error) {
+	pair, _, err := ConsulClient.KV().Get(key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, 0, fmt.Errorf("read %s: %w", key, err)
+	}
+	if pair == nil {
+		return nil, 0, nil
+	}
+	var v ConfigVersion
+	if err := json.Unmarshal(pair.Value, &v); err != nil {
+		return nil, 0, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return &v, pair.ModifyIndex, nil
+}
+
+func readVersion(ctx context.Context, prefix string, version int) (*ConfigVersion, map[string]string, error) {
+	if ConsulClient == nil {
+		return nil, nil, fmt.Errorf("consul client not initialized")
+	}
+	history := prefix + "/history/" + strconv.Itoa(version)
+	v, _, err := readMeta(ctx, history+"/meta")
+	if err != nil {
+		return nil, nil, err
+	}
+	if v == nil {
+		return nil, nil, fmt.Errorf("config version %d of %s not found", version, prefix)
+	}
+
+	pairs, _, err := ConsulClient.KV().List(history+"/files/", (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read config version %d: %w", version, err)
+	}
+	files := map[string]string{}
+	for _, p := range pairs {
+		files[strings.TrimPrefix(p.Key, history+"/files/")] = string(p.Value)
+	}
+	return v, files, nil
+}
+
+func checksumFiles(files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00%s", name, len(files[name]), files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func validFileName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || path.Clean(name) != name || strings.HasPrefix(name, "..") {
+		return fmt.Errorf("invalid config file name %q", name)
+	}
+	return nil
+}
@@ -1,135 +1,216 @@
 package consul
 
 import (
-	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
-	"log"
-	"reflect"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/hashicorp/consul/api"
 
 	"github.com/bsonger/devflow-common/model"
 )
 
 var ConsulClient *api.Client
-var lastIndex uint64
 
+// checkTimeout 初始化时连通性检查的超时
+const checkTimeout = 5 * time.Second
+
+// InitConsulClient 按配置创建 Consul client（ACL token、TLS、datacenter、namespace），
+// 并检查连通性和 key 的读取权限，失败时返回具体原因
 func InitConsulClient(consul *model.Consul) error {
-	cfg := api.DefaultConfig()
-	cfg.Address = consul.Address
+	cfg, err := clientConfig(consul)
+	if err != nil {
+		return err
+	}
 	c, err := api.NewClient(cfg)
 	if err != nil {
+		return fmt.Errorf("create consul client: %w", err)
+	}
+	if err := checkConsul(c, consul, cfg.Address); err != nil {
 		return err
 	}
 	ConsulClient = c
 	return nil
 }
 
-func LoadConsulConfigAndMerge(c *model.Consul) error {
-	if ConsulClient == nil {
-		return nil
+func clientConfig(consul *model.Consul) (*api.Config, error) {
+	cfg := api.DefaultConfig()
+	if consul.Address != "" {
+		cfg.Address = consul.Address
+	}
+	if consul.Datacenter != "" {
+		cfg.Datacenter = consul.Datacenter
+	}
+	if consul.Namespace != "" {
+		cfg.Namespace = consul.Namespace
 	}
 
-	kv := ConsulClient.KV()
-	pair, _, err := kv.Get(c.Key, nil)
+	token, err := consulToken(consul)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if pair == nil {
-		return nil
+	if token != "" {
+		cfg.Token = token
 	}
 
-	cfg := &model.Config{}
-	if err := yaml.Unmarshal(pair.Value, cfg); err != nil {
-		return err
+	if tls := consul.TLS; tls != nil {
+		cfg.Scheme = "https"
+		cfg.TLSConfig.CAFile = tls.CAFile
+		cfg.TLSConfig.CertFile = tls.CertFile
+		cfg.TLSConfig.KeyFile = tls.KeyFile
+		cfg.TLSConfig.Address = tls.ServerName
+		cfg.TLSConfig.InsecureSkipVerify = tls.InsecureSkipVerify
 	}
-	MergeConfig(model.C, cfg)
-	return nil
+	return cfg, nil
 }
 
-func MergeConfig(oldCfg, newCfg *model.Config) {
-	mergeStructs(oldCfg, newCfg)
+// consulToken 为空时沿用 api.DefaultConfig 从 CONSUL_HTTP_TOKEN 读取的 token
+func consulToken(consul *model.Consul) (string, error) {
+	switch {
+	case consul.Token != "":
+		return consul.Token, nil
+	case consul.TokenFile != "":
+		data, err := os.ReadFile(consul.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read consul token file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("consul token file %s is empty", consul.TokenFile)
+		}
+		return token, nil
+	case consul.TokenEnv != "":
+		token := os.Getenv(consul.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("consul token env %s is not set", consul.TokenEnv)
+		}
+		return token, nil
+	}
+	return "", nil
 }
 
-func mergeStructs(dst, src interface{}) {
-	dv := reflect.ValueOf(dst).Elem()
-	sv := reflect.ValueOf(src).Elem()
+func checkConsul(c *api.Client, consul *model.Consul, address string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	q := (&api.QueryOptions{}).WithContext(ctx)
 
-	for i := 0; i < dv.NumField(); i++ {
-		df := dv.Field(i)
-		sf := sv.Field(i)
-		if isNil(sf) {
-			continue
-		}
-		switch df.Kind() {
-		case reflect.Struct:
-			mergeStructs(df.Addr().Interface(), sf.Addr().Interface())
-		default:
-			// 覆盖逻辑：src 有值则覆盖
-			if !isZero(sf) {
-				df.Set(sf)
-			}
+	leader, err := c.Status().LeaderWithQueryOptions(q)
+	if err != nil {
+		return fmt.Errorf("consul %s unreachable: %w", address, err)
+	}
+	if leader == "" {
+		return fmt.Errorf("consul %s has no cluster leader", address)
+	}
+
+	key := consul.Key
+	read := func() error { _, _, err := c.KV().Get(key, q); return err }
+	if consul.Prefix != "" {
+		key = consul.Prefix
+		read = func() error { _, _, err := c.KV().Keys(key, "", q); return err }
+	}
+	if key == "" {
+		return nil
+	}
+	if err := read(); err != nil {
+		var statusErr api.StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden {
+			return fmt.Errorf("consul token is not permitted to read %s: %w", key, err)
 		}
+		return fmt.Errorf("read consul %s: %w", key, err)
 	}
+	return nil
 }
 
-func isNil(v reflect.Value) bool {
-	switch v.Kind() {
-	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
-		return v.IsNil()
+// LoadConsulConfigAndMerge 读取 consul.key（或 consul.prefix 下每个配置段一个 key）并合并到全局配置
+func LoadConsulConfigAndMerge(c *model.Consul) error {
+	if ConsulClient == nil {
+		return nil
 	}
-	return false
-}
 
-func isZero(v reflect.Value) bool {
-	switch v.Kind() {
-	case reflect.String:
-		return v.String() == ""
-	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		return v.Int() == 0
-	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
-		return v.Uint() == 0
+	value, err := ReadConfig(c)
+	if err != nil || value == nil {
+		return err
 	}
-	return false
+	return applyYAML(value)
 }
 
-func WatchConsul(c *model.Consul, logger *zap.Logger) {
+// ReadConfig 读取配置并统一转换成 YAML 文档，配置不存在时返回 nil
+// 设置 Prefix 时从前缀下每个配置段一个 key 组装，否则读取 Key；格式见 DetectFormat
+func ReadConfig(c *model.Consul) ([]byte, error) {
 	if ConsulClient == nil {
-		return
+		return nil, fmt.Errorf("consul client not initialized")
 	}
 
-	go func() {
-		kv := ConsulClient.KV()
+	if c.Prefix != "" {
+		pairs, _, err := ConsulClient.KV().List(c.Prefix, nil)
+		if err != nil {
+			return nil, fmt.Errorf("read consul prefix %s: %w", c.Prefix, err)
+		}
+		if len(pairs) == 0 {
+			return nil, nil
+		}
+		return assemblePrefix(c.Prefix, c.Format, pairs)
+	}
 
-		for {
-			pair, meta, err := kv.Get(c.Key, &api.QueryOptions{
-				WaitIndex: lastIndex,
-				WaitTime:  60 * time.Second, // 长轮询
-			})
-			if err != nil {
-				log.Println("Consul watch error:", err)
-				time.Sleep(1 * time.Second)
-				continue
-			}
+	pair, _, err := ConsulClient.KV().Get(c.Key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("read consul key %s: %w", c.Key, err)
+	}
+	if pair == nil {
+		return nil, nil
+	}
+	return toYAML(c.Key, c.Format, pair.Value)
+}
 
-			// 没有变化
-			if pair == nil || meta.LastIndex == lastIndex {
-				continue
-			}
+// ConfigHandler 按内容识别格式（YAML/JSON/TOML）并应用到全局配置，
+// 文档中出现的字段才会覆盖（包括显式零值和 null），解析或校验失败时保留上一份可用配置
+func ConfigHandler(value []byte) error {
+	return apply(func(c *model.Config) error {
+		data, err := toYAML("", "", value)
+		if err != nil {
+			return err
+		}
+		return MergeYAML(c, data)
+	})
+}
 
-			lastIndex = meta.LastIndex
+// keyHandler 按 key 的扩展名或配置的格式解析
+func keyHandler(consul *model.Consul) Handler {
+	return func(value []byte) error {
+		return apply(func(c *model.Config) error {
+			data, err := toYAML(consul.Key, consul.Format, value)
+			if err != nil {
+				return err
+			}
+			return MergeYAML(c, data)
+		})
+	}
+}
 
-			logger.Info("🟢 侦测到 Consul 配置变化，重新加载")
+func applyYAML(value []byte) error {
+	return apply(func(c *model.Config) error {
+		return MergeYAML(c, value)
+	})
+}
 
-			newCfg := &model.Config{}
-			if err := yaml.Unmarshal(pair.Value, newCfg); err != nil {
-				log.Println("Consul 配置解析失败:", err)
-				continue
-			}
+// WatchConsul 在后台监听配置 key（或 prefix），返回的 Watcher 可用于 Stop
+func WatchConsul(c *model.Consul, logger *zap.Logger) *Watcher {
+	if ConsulClient == nil {
+		return nil
+	}
 
-			// 覆盖全局配置
-			MergeConfig(model.C, newCfg)
-		}
-	}()
+	var w *Watcher
+	if c.Prefix != "" {
+		w = NewPrefixWatcher(ConsulClient, c.Prefix, c.Format, applyYAML, logger)
+	} else {
+		w = NewWatcher(ConsulClient, c.Key, keyHandler(c), logger)
+	}
+	w.Start(context.Background())
+	return w
 }
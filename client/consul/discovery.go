@@ -0,0 +1,205 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+)
+
+// ErrNoInstances 服务当前没有健康的实例
+var ErrNoInstances = errors.New("no healthy instances")
+
+// Instance 服务的一个健康实例
+type Instance struct {
+	ID      string
+	Address string
+	Port    int
+	Tags    []string
+	Meta    map[string]string
+}
+
+// HostPort 返回 address:port
+func (i Instance) HostPort() string {
+	return net.JoinHostPort(i.Address, strconv.Itoa(i.Port))
+}
+
+// Resolver 通过 blocking query 缓存服务的健康实例，Pick 按轮询选择实例
+type Resolver struct {
+	client  *api.Client
+	service string
+	tag     string
+	logger  *zap.Logger
+
+	index     uint64
+	instances atomic.Pointer[[]Instance]
+	next      atomic.Uint64
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewResolver(client *api.Client, service, tag string, logger *zap.Logger) *Resolver {
+	return &Resolver{
+		client:  client,
+		service: service,
+		tag:     tag,
+		logger:  logger,
+		ready:   make(chan struct{}),
+	}
+}
+
+// Start 在后台持续刷新实例列表，重复调用无效；ctx 结束或调用 Stop 时退出
+func (r *Resolver) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+// Stop 停止刷新并等待后台 goroutine 退出，已缓存的实例仍可使用
+func (r *Resolver) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel = nil
+	r.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wait 等待第一次查询完成
+func (r *Resolver) Wait(ctx context.Context) error {
+	select {
+	case <-r.ready:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("resolve %s: %w", r.service, ctx.Err())
+	}
+}
+
+// Instances 返回缓存的健康实例
+func (r *Resolver) Instances() []Instance {
+	if p := r.instances.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// Pick 轮询选择一个健康实例
+func (r *Resolver) Pick() (Instance, error) {
+	instances := r.Instances()
+	if len(instances) == 0 {
+		return Instance{}, fmt.Errorf("service %s: %w", r.service, ErrNoInstances)
+	}
+	n := r.next.Add(1) - 1
+	return instances[n%uint64(len(instances))], nil
+}
+
+func (r *Resolver) run(ctx context.Context) {
+	defer close(r.done)
+
+	backoff := minBackoff
+	for {
+		opts := (&api.QueryOptions{
+			WaitIndex: r.index,
+			WaitTime:  watchWaitTime,
+		}).WithContext(ctx)
+
+		entries, meta, err := r.client.Health().Service(r.service, r.tag, true, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// 查询失败时保留上一次的实例列表
+			r.logger.Warn("consul service query error", zap.String("service", r.service), zap.Duration("retry_in", backoff), zap.Error(err))
+			if !sleep(ctx, jitter(backoff)) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+
+		if meta.LastIndex < r.index {
+			r.index = 0
+			continue
+		}
+		if meta.LastIndex == r.index {
+			continue
+		}
+		r.index = max(meta.LastIndex, 1)
+
+		instances := make([]Instance, 0, len(entries))
+		for _, e := range entries {
+			addr := e.Service.Address
+			if addr == "" {
+				addr = e.Node.Address
+			}
+			instances = append(instances, Instance{
+				ID:      e.Service.ID,
+				Address: addr,
+				Port:    e.Service.Port,
+				Tags:    e.Service.Tags,
+				Meta:    e.Service.Meta,
+			})
+		}
+		r.instances.Store(&instances)
+		r.readyOnce.Do(func() { close(r.ready) })
+		r.logger.Debug("consul service instances updated", zap.String("service", r.service), zap.Int("count", len(instances)))
+	}
+}
+
+var (
+	resolversMu sync.Mutex
+	resolvers   = map[string]*Resolver{}
+)
+
+// Resolve 使用 ConsulClient 返回 service 的一个健康实例
+// 每个 service 首次调用时启动一个 Resolver 并等待第一次查询，之后从缓存中轮询选择
+func Resolve(ctx context.Context, service string, logger *zap.Logger) (Instance, error) {
+	if ConsulClient == nil {
+		return Instance{}, fmt.Errorf("consul client not initialized")
+	}
+
+	resolversMu.Lock()
+	r, ok := resolvers[service]
+	if !ok {
+		r = NewResolver(ConsulClient, service, "", logger)
+		r.Start(context.Background())
+		resolvers[service] = r
+	}
+	resolversMu.Unlock()
+
+	if err := r.Wait(ctx); err != nil {
+		return Instance{}, err
+	}
+	return r.Pick()
+}
+
+// StopResolvers 停止 Resolve 启动的所有 Resolver
+func StopResolvers() {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	for name, r := range resolvers {
+		r.Stop()
+		delete(resolvers, name)
+	}
+}
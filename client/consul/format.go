@@ -0,0 +1,113 @@
+package consul
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置内容格式，model.Consul.Format 为空时自动识别
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// DetectFormat 先按 key 的扩展名（.yaml/.yml/.json/.toml）识别，否则按内容识别，默认 YAML
+func DetectFormat(key string, data []byte) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return FormatJSON
+	}
+	if looksLikeTOML(trimmed) {
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// looksLikeTOML 第一行有效内容是 [table] 或 key = value
+func looksLikeTOML(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.Contains(line, ",") {
+			return true
+		}
+		eq := strings.Index(line, "=")
+		colon := strings.Index(line, ":")
+		return eq > 0 && (colon < 0 || eq < colon)
+	}
+	return false
+}
+
+// toYAML 把 format 格式的内容转换成 YAML，format 为空时自动识别
+func toYAML(key, format string, data []byte) ([]byte, error) {
+	if format == "" {
+		format = DetectFormat(key, data)
+	}
+	switch format {
+	case FormatYAML:
+		return data, nil
+	case FormatJSON:
+		// JSON 是 YAML 的子集，校验后按 YAML 解析，保留数字的原始写法
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("parse config: invalid JSON")
+		}
+		return data, nil
+	case FormatTOML:
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+		return yaml.Marshal(doc)
+	}
+	return nil, fmt.Errorf("unsupported config format %q", format)
+}
+
+// assemblePrefix 把 prefix 下每个配置段一个 key 的内容组装成一个 YAML 文档
+// key 名为配置段名，可以带格式扩展名，例如 <prefix>/mongo.json；更深层级的 key 会被忽略
+// 只有存在的段会参与合并，删除某个段的 key 不会清空该段
+func assemblePrefix(prefix, format string, pairs api.KVPairs) ([]byte, error) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	doc := map[string]interface{}{}
+	for _, p := range pairs {
+		name := strings.TrimPrefix(p.Key, prefix)
+		if name == "" || strings.Contains(name, "/") || len(bytes.TrimSpace(p.Value)) == 0 {
+			continue
+		}
+		section := strings.TrimSuffix(name, path.Ext(name))
+		if _, ok := sectionField(section); !ok {
+			return nil, fmt.Errorf("key %s: unknown config section %q", p.Key, section)
+		}
+
+		data, err := toYAML(name, format, p.Value)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", p.Key, err)
+		}
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("key %s: parse config: %w", p.Key, err)
+		}
+		doc[section] = v
+	}
+	return yaml.Marshal(doc)
+}
@@ -0,0 +1,176 @@
+package consul
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+// 合并策略
+//
+// 覆盖判断：
+//   - MergeYAML 按原始文档判断：文档中出现的字段才覆盖，出现的零值（0、""、false）同样覆盖，
+//     显式写 null 的字段重置为零值，map 中值为 null 的 key 被删除
+//   - MergeConfig 只有结构体可用：非零值视为设置，零值视为未设置，因此无法显式置零
+//
+// 按类型合并（可通过字段 tag merge:"replace|append|deep" 覆盖默认策略）：
+//   - string、数字、bool、time.Duration 等标量：直接覆盖
+//   - 结构体、结构体指针：默认 deep，逐字段递归合并；replace 时整体替换
+//   - map：默认 deep，逐 key 合并，值为结构体指针时递归合并；replace 时整体替换
+//   - slice：默认 replace，整体替换（例如 environments 的顺序有意义）；append 时追加到末尾
+const mergeTag = "merge"
+
+const (
+	MergeDeep    = "deep"
+	MergeReplace = "replace"
+	MergeAppend  = "append"
+)
+
+// MergeConfig 原地合并配置，src 中非零的字段覆盖 dst，不会通知订阅者
+// 不要直接修改 model.Current() 的快照，热更新请使用 ApplyConfig
+func MergeConfig(dst, src *model.Config) {
+	if dst == nil || src == nil {
+		return
+	}
+	mergeStruct(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), nil, false)
+}
+
+// MergeYAML 把 YAML 文档原地合并到 dst，只覆盖文档中出现的字段
+func MergeYAML(dst *model.Config, data []byte) error {
+	src := &model.Config{}
+	if err := yaml.Unmarshal(data, src); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	mergeDocument(dst, src, doc)
+	return nil
+}
+
+// mergeDocument 按原始文档 doc 判断字段是否出现，src 为同一文档解析出的结构体
+func mergeDocument(dst, src *model.Config, doc map[string]interface{}) {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	mergeStruct(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), doc, true)
+}
+
+// mergeStruct doc 为 true 时按 raw 判断字段是否出现，否则按 src 是否为零值判断
+func mergeStruct(dst, src reflect.Value, raw map[string]interface{}, doc bool) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		df, sf := dst.Field(i), src.Field(i)
+		if !df.CanSet() {
+			continue
+		}
+		if isInline(f) {
+			mergeStruct(df, sf, raw, doc)
+			continue
+		}
+
+		var fieldRaw interface{}
+		if doc {
+			v, ok := raw[sectionName(f)]
+			if !ok {
+				continue
+			}
+			if v == nil {
+				df.Set(reflect.Zero(f.Type))
+				continue
+			}
+			fieldRaw = v
+		} else if sf.IsZero() {
+			continue
+		}
+		mergeValue(df, sf, fieldRaw, doc, f.Tag.Get(mergeTag))
+	}
+}
+
+func mergeValue(dst, src reflect.Value, raw interface{}, doc bool, policy string) {
+	if policy == MergeReplace {
+		dst.Set(src)
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		mergeStruct(dst, src, asMap(raw), doc)
+		return
+	case reflect.Ptr:
+		if src.IsNil() || dst.Type().Elem().Kind() != reflect.Struct {
+			break
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		mergeStruct(dst.Elem(), src.Elem(), asMap(raw), doc)
+		return
+	case reflect.Map:
+		mergeMap(dst, src, asMap(raw), doc)
+		return
+	case reflect.Slice:
+		if policy == MergeAppend {
+			dst.Set(reflect.AppendSlice(dst, src))
+			return
+		}
+	}
+	dst.Set(src)
+}
+
+func mergeMap(dst, src reflect.Value, raw map[string]interface{}, doc bool) {
+	if src.IsNil() {
+		return
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), src.Len()))
+	}
+
+	iter := src.MapRange()
+	for iter.Next() {
+		key, sv := iter.Key(), iter.Value()
+		if isNilValue(sv) {
+			// 值为 null 表示删除该 key
+			dst.SetMapIndex(key, reflect.Value{})
+			continue
+		}
+
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		if dv := dst.MapIndex(key); dv.IsValid() {
+			elem.Set(dv)
+		}
+		var keyRaw interface{}
+		if raw != nil {
+			keyRaw = raw[fmt.Sprint(key.Interface())]
+		}
+		mergeValue(elem, sv, keyRaw, doc, "")
+		dst.SetMapIndex(key, elem)
+	}
+}
+
+func isInline(f reflect.StructField) bool {
+	if f.Type.Kind() != reflect.Struct {
+		return false
+	}
+	_, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return f.Anonymous || strings.Contains(opts, "inline")
+}
+
+func asMap(raw interface{}) map[string]interface{} {
+	m, _ := raw.(map[string]interface{})
+	return m
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package consul
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+func baseConfig() *model.Config {
+	return &model.Config{
+		Server: &model.ServerConfig{Port: 8080},
+		Mongo:  &model.MongoConfig{URI: "mongodb://localhost:27017", DBName: "devflow"},
+		Log:    &model.LogConfig{Level: "info", Format: "json"},
+		Consul: &model.Consul{
+			Address: "consul:8500",
+			Key:     "devflow/config",
+			TLS:     &model.ConsulTLS{CAFile: "/etc/ca.pem", CertFile: "/etc/old.pem", KeyFile: "/etc/old.key"},
+		},
+		Argo: &model.ArgoConfig{
+			ArgoTarget:     model.ArgoTarget{Project: "app", Plugin: "plugin", Server: "https://kubernetes.default.svc"},
+			Namespace:      "argo-cd",
+			ManageProjects: true,
+			Applications: map[string]*model.ArgoTarget{
+				"web": {Project: "web", Plugin: "helm"},
+				"api": {Project: "api"},
+			},
+		},
+		Release: &model.ReleaseConfig{Windows: []model.ReleaseWindow{
+			{Kind: model.WindowDeny, Schedule: "0 0 * * 6", Duration: "48h"},
+			{Kind: model.WindowAllow, Schedule: "0 9 * * 1-5", Duration: "8h"},
+		}},
+		Env:          "dev",
+		Environments: []string{"dev", "staging", "prod"},
+	}
+}
+
+func mustMergeYAML(t *testing.T, dst *model.Config, doc string) {
+	t.Helper()
+	if err := MergeYAML(dst, []byte(doc)); err != nil {
+		t.Fatalf("MergeYAML: %v", err)
+	}
+}
+
+func TestMergeYAMLExplicitZeroOverrides(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+server:
+  port: 0
+env: ""
+argo:
+  manage_projects: false
+`)
+
+	if cfg.Server.Port != 0 {
+		t.Errorf("server.port = %d, want 0", cfg.Server.Port)
+	}
+	if cfg.Env != "" {
+		t.Errorf("env = %q, want empty", cfg.Env)
+	}
+	if cfg.Argo.ManageProjects {
+		t.Error("argo.manage_projects = true, want false")
+	}
+}
+
+func TestMergeYAMLKeepsAbsentFields(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+log:
+  level: debug
+`)
+
+	if cfg.Log.Level != "debug" {
+		t.Errorf("log.level = %q, want debug", cfg.Log.Level)
+	}
+	if cfg.Log.Format != "json" {
+		t.Errorf("log.format = %q, want json", cfg.Log.Format)
+	}
+	if want := baseConfig(); !reflect.DeepEqual(cfg.Mongo, want.Mongo) || cfg.Env != want.Env {
+		t.Errorf("sections not in the document changed: mongo=%+v env=%q", cfg.Mongo, cfg.Env)
+	}
+}
+
+func TestMergeYAMLNullResets(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+log: null
+mongo:
+  db: null
+environments: null
+`)
+
+	if cfg.Log != nil {
+		t.Errorf("log = %+v, want nil", cfg.Log)
+	}
+	if cfg.Mongo.DBName != "" {
+		t.Errorf("mongo.db = %q, want empty", cfg.Mongo.DBName)
+	}
+	if cfg.Mongo.URI == "" {
+		t.Error("mongo.uri was reset, want kept")
+	}
+	if cfg.Environments != nil {
+		t.Errorf("environments = %v, want nil", cfg.Environments)
+	}
+}
+
+func TestMergeYAMLMapPerKey(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+argo:
+  applications:
+    web:
+      plugin: ""
+    api: null
+    worker:
+      project: jobs
+`)
+
+	apps := cfg.Argo.Applications
+	if len(apps) != 2 {
+		t.Fatalf("applications = %v, want web and worker", apps)
+	}
+	if web := apps["web"]; web == nil || web.Project != "web" || web.Plugin != "" {
+		t.Errorf("applications.web = %+v, want project kept and plugin cleared", web)
+	}
+	if _, ok := apps["api"]; ok {
+		t.Error("applications.api still present, want deleted by null")
+	}
+	if worker := apps["worker"]; worker == nil || worker.Project != "jobs" {
+		t.Errorf("applications.worker = %+v, want project jobs", worker)
+	}
+}
+
+func TestMergeYAMLSliceReplace(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+environments: [staging, prod]
+release:
+  windows:
+    - kind: deny
+      schedule: "0 0 24 12 *"
+      duration: 24h
+`)
+
+	if want := []string{"staging", "prod"}; !reflect.DeepEqual(cfg.Environments, want) {
+		t.Errorf("environments = %v, want %v", cfg.Environments, want)
+	}
+	if len(cfg.Release.Windows) != 1 || cfg.Release.Windows[0].Schedule != "0 0 24 12 *" {
+		t.Errorf("release.windows = %+v, want only the new window", cfg.Release.Windows)
+	}
+}
+
+func TestMergeYAMLInlineArgoTarget(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+argo:
+  project: platform
+  cluster: prod-cluster
+`)
+
+	if cfg.Argo.Project != "platform" || cfg.Argo.Cluster != "prod-cluster" {
+		t.Errorf("argo target = %+v, want project and cluster updated", cfg.Argo.ArgoTarget)
+	}
+	if cfg.Argo.Plugin != "plugin" || cfg.Argo.Server != "https://kubernetes.default.svc" {
+		t.Errorf("argo target = %+v, want plugin and server kept", cfg.Argo.ArgoTarget)
+	}
+	if cfg.Argo.Namespace != "argo-cd" {
+		t.Errorf("argo.namespace = %q, want kept", cfg.Argo.Namespace)
+	}
+}
+
+func TestMergeYAMLPointerSection(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Otel = nil
+	mustMergeYAML(t, cfg, `
+otel:
+  service_name: devflow
+mongo:
+  db: other
+`)
+
+	if cfg.Otel == nil || cfg.Otel.ServiceName != "devflow" {
+		t.Errorf("otel = %+v, want allocated with service_name", cfg.Otel)
+	}
+	if cfg.Mongo.DBName != "other" || cfg.Mongo.URI != "mongodb://localhost:27017" {
+		t.Errorf("mongo = %+v, want db updated and uri kept", cfg.Mongo)
+	}
+}
+
+func TestMergeYAMLReplacePolicy(t *testing.T) {
+	cfg := baseConfig()
+	mustMergeYAML(t, cfg, `
+consul:
+  tls:
+    cert_file: /etc/new.pem
+    key_file: /etc/new.key
+`)
+
+	want := &model.ConsulTLS{CertFile: "/etc/new.pem", KeyFile: "/etc/new.key"}
+	if !reflect.DeepEqual(cfg.Consul.TLS, want) {
+		t.Errorf("consul.tls = %+v, want %+v (replaced, ca_file dropped)", cfg.Consul.TLS, want)
+	}
+	if cfg.Consul.Address != "consul:8500" {
+		t.Errorf("consul.address = %q, want kept", cfg.Consul.Address)
+	}
+}
+
+func TestMergeYAMLDoesNotAliasSource(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Argo.Applications = nil
+	mustMergeYAML(t, cfg, `
+argo:
+  applications:
+    web:
+      project: web
+`)
+	before := *cfg.Argo.Applications["web"]
+
+	next := cfg.Clone()
+	mustMergeYAML(t, next, `
+argo:
+  applications:
+    web:
+      project: changed
+`)
+	if *cfg.Argo.Applications["web"] != before {
+		t.Errorf("merging into a clone changed the original: %+v", cfg.Argo.Applications["web"])
+	}
+}
+
+func TestMergeConfigSkipsZeroValues(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Argo.ManageProjects = false
+	MergeConfig(cfg, &model.Config{
+		Server: &model.ServerConfig{Port: 0},
+		Argo:   &model.ArgoConfig{ManageProjects: true},
+		Env:    "prod",
+	})
+
+	if cfg.Server.Port != 8080 {
+		t.Errorf("server.port = %d, want 8080 (zero is not set)", cfg.Server.Port)
+	}
+	if !cfg.Argo.ManageProjects {
+		t.Error("argo.manage_projects = false, want true")
+	}
+	if cfg.Argo.Namespace != "argo-cd" {
+		t.Errorf("argo.namespace = %q, want kept", cfg.Argo.Namespace)
+	}
+	if cfg.Env != "prod" {
+		t.Errorf("env = %q, want prod", cfg.Env)
+	}
+}
+
+type policyConfig struct {
+	Ratio   float64           `yaml:"ratio"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Enabled bool              `yaml:"enabled"`
+	Tags    []string          `yaml:"tags" merge:"append"`
+	Hosts   []string          `yaml:"hosts"`
+	Labels  map[string]string `yaml:"labels" merge:"replace"`
+	Extra   map[string]string `yaml:"extra"`
+}
+
+func mergePolicyDoc(t *testing.T, dst *policyConfig, doc string) {
+	t.Helper()
+	src := &policyConfig{}
+	if err := yaml.Unmarshal([]byte(doc), src); err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
+		t.Fatal(err)
+	}
+	mergeStruct(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), raw, true)
+}
+
+func TestMergeScalarKinds(t *testing.T) {
+	cfg := &policyConfig{Ratio: 0.5, Timeout: time.Minute, Enabled: true}
+	mergePolicyDoc(t, cfg, `
+ratio: 0
+timeout: 0s
+enabled: false
+`)
+	if cfg.Ratio != 0 || cfg.Timeout != 0 || cfg.Enabled {
+		t.Errorf("got %+v, want float, duration and bool set to zero", cfg)
+	}
+
+	mergePolicyDoc(t, cfg, `
+ratio: 1.5
+timeout: 30s
+`)
+	if cfg.Ratio != 1.5 || cfg.Timeout != 30*time.Second {
+		t.Errorf("got %+v, want ratio 1.5 and timeout 30s", cfg)
+	}
+}
+
+func TestMergePolicies(t *testing.T) {
+	cfg := &policyConfig{
+		Tags:   []string{"a"},
+		Hosts:  []string{"h1"},
+		Labels: map[string]string{"team": "ops", "tier": "web"},
+		Extra:  map[string]string{"k1": "v1"},
+	}
+	mergePolicyDoc(t, cfg, `
+tags: [b]
+hosts: [h2]
+labels:
+  team: dev
+extra:
+  k2: v2
+`)
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(cfg.Tags, want) {
+		t.Errorf("tags = %v, want appended %v", cfg.Tags, want)
+	}
+	if want := []string{"h2"}; !reflect.DeepEqual(cfg.Hosts, want) {
+		t.Errorf("hosts = %v, want replaced %v", cfg.Hosts, want)
+	}
+	if want := map[string]string{"team": "dev"}; !reflect.DeepEqual(cfg.Labels, want) {
+		t.Errorf("labels = %v, want replaced %v", cfg.Labels, want)
+	}
+	if want := map[string]string{"k1": "v1", "k2": "v2"}; !reflect.DeepEqual(cfg.Extra, want) {
+		t.Errorf("extra = %v, want merged %v", cfg.Extra, want)
+	}
+}
@@ -0,0 +1,211 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+const (
+	defaultCheckInterval   = 10 * time.Second
+	defaultCheckTimeout    = 5 * time.Second
+	defaultDeregisterAfter = time.Minute
+)
+
+// RegisterOptions 服务注册参数
+type RegisterOptions struct {
+	Name    string
+	ID      string // 为空时使用 name-hostname-port
+	Address string // 为空时使用环境变量 POD_IP，其次是第一个非回环 IPv4 地址
+	Port    int
+	Tags    []string
+	Meta    map[string]string
+
+	// HealthPath 设置时使用 HTTP 健康检查 http://address:port/HealthPath，否则使用 TTL 检查并由 Registrar 定期上报
+	HealthPath      string
+	CheckInterval   time.Duration
+	DeregisterAfter time.Duration // 健康检查持续失败多久后由 Consul 自动注销
+}
+
+// Registrar 把当前服务注册到 Consul agent，Deregister 时注销
+type Registrar struct {
+	client *api.Client
+	opts   RegisterOptions
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRegistrar(client *api.Client, opts RegisterOptions, logger *zap.Logger) *Registrar {
+	return &Registrar{client: client, opts: opts, logger: logger}
+}
+
+// RegisterService 使用 ConsulClient 注册当前服务，名称取 otel.service_name，端口取 server.port
+func RegisterService(ctx context.Context, cfg *model.Config, logger *zap.Logger) (*Registrar, error) {
+	if ConsulClient == nil {
+		return nil, fmt.Errorf("consul client not initialized")
+	}
+	if cfg == nil || cfg.Otel == nil || cfg.Otel.ServiceName == "" {
+		return nil, fmt.Errorf("register service: otel.service_name is required")
+	}
+	if cfg.Server == nil || cfg.Server.Port == 0 {
+		return nil, fmt.Errorf("register service: server.port is required")
+	}
+
+	r := NewRegistrar(ConsulClient, RegisterOptions{
+		Name: cfg.Otel.ServiceName,
+		Port: cfg.Server.Port,
+		Meta: map[string]string{"env": model.Environment()},
+	}, logger)
+	if err := r.Register(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Register 注册服务，使用 TTL 检查时在后台定期上报健康状态，重复调用无效
+func (r *Registrar) Register(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return nil
+	}
+
+	if err := r.defaults(); err != nil {
+		return err
+	}
+	reg := r.registration()
+	if err := r.client.Agent().ServiceRegisterOpts(reg, api.ServiceRegisterOpts{}.WithContext(ctx)); err != nil {
+		return fmt.Errorf("register service %s: %w", r.opts.Name, err)
+	}
+	r.logger.Info("service registered", zap.String("service", r.opts.Name), zap.String("id", r.opts.ID),
+		zap.String("address", net.JoinHostPort(r.opts.Address, strconv.Itoa(r.opts.Port))))
+
+	hctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	if r.opts.HealthPath == "" {
+		go r.heartbeat(hctx)
+	} else {
+		close(r.done)
+	}
+	return nil
+}
+
+// Deregister 停止上报并从 Consul 注销服务，一般在服务退出时调用
+func (r *Registrar) Deregister() error {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel = nil
+	r.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+
+	if err := r.client.Agent().ServiceDeregister(r.opts.ID); err != nil {
+		return fmt.Errorf("deregister service %s: %w", r.opts.ID, err)
+	}
+	r.logger.Info("service deregistered", zap.String("service", r.opts.Name), zap.String("id", r.opts.ID))
+	return nil
+}
+
+func (r *Registrar) defaults() error {
+	if r.opts.Name == "" || r.opts.Port == 0 {
+		return fmt.Errorf("register service: name and port are required")
+	}
+	if r.opts.Address == "" {
+		addr, err := localAddress()
+		if err != nil {
+			return fmt.Errorf("register service %s: %w", r.opts.Name, err)
+		}
+		r.opts.Address = addr
+	}
+	if r.opts.ID == "" {
+		host, _ := os.Hostname()
+		r.opts.ID = fmt.Sprintf("%s-%s-%d", r.opts.Name, host, r.opts.Port)
+	}
+	if r.opts.CheckInterval == 0 {
+		r.opts.CheckInterval = defaultCheckInterval
+	}
+	if r.opts.DeregisterAfter == 0 {
+		r.opts.DeregisterAfter = defaultDeregisterAfter
+	}
+	return nil
+}
+
+func (r *Registrar) checkID() string {
+	return "service:" + r.opts.ID
+}
+
+func (r *Registrar) registration() *api.AgentServiceRegistration {
+	check := &api.AgentServiceCheck{
+		CheckID:                        r.checkID(),
+		DeregisterCriticalServiceAfter: r.opts.DeregisterAfter.String(),
+	}
+	if r.opts.HealthPath != "" {
+		check.HTTP = fmt.Sprintf("http://%s%s", net.JoinHostPort(r.opts.Address, strconv.Itoa(r.opts.Port)), r.opts.HealthPath)
+		check.Interval = r.opts.CheckInterval.String()
+		check.Timeout = defaultCheckTimeout.String()
+	} else {
+		// TTL 为上报间隔的 3 倍，允许偶尔丢失一次上报
+		check.TTL = (3 * r.opts.CheckInterval).String()
+		check.Status = api.HealthPassing
+	}
+
+	return &api.AgentServiceRegistration{
+		ID:      r.opts.ID,
+		Name:    r.opts.Name,
+		Address: r.opts.Address,
+		Port:    r.opts.Port,
+		Tags:    r.opts.Tags,
+		Meta:    r.opts.Meta,
+		Check:   check,
+	}
+}
+
+func (r *Registrar) heartbeat(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := r.client.Agent().UpdateTTLOpts(r.checkID(), "", api.HealthPassing, (&api.QueryOptions{}).WithContext(ctx)); err != nil && ctx.Err() == nil {
+			r.logger.Warn("consul ttl update failed", zap.String("check_id", r.checkID()), zap.Error(err))
+		}
+	}
+}
+
+func localAddress() (string, error) {
+	if ip := os.Getenv("POD_IP"); ip != "" {
+		return ip, nil
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no non-loopback IPv4 address found")
+}
@@ -0,0 +1,46 @@
+package consul
+
+import (
+	"sync"
+	"time"
+)
+
+// Status 配置热更新状态，用于健康检查
+type Status struct {
+	// Version 成功应用的配置版本，每次成功加 1
+	Version     uint64    `json:"version"`
+	AppliedAt   time.Time `json:"applied_at,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// Healthy 最近一次加载是否成功
+func (s Status) Healthy() bool {
+	return s.LastError == "" || s.AppliedAt.After(s.LastErrorAt)
+}
+
+var (
+	statusMu sync.RWMutex
+	status   Status
+)
+
+// ConfigStatus 返回当前配置的版本和最近一次错误
+func ConfigStatus() Status {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	return status
+}
+
+func recordSuccess() {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status.Version++
+	status.AppliedAt = time.Now()
+}
+
+func recordFailure(err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status.LastError = err.Error()
+	status.LastErrorAt = time.Now()
+}
@@ -0,0 +1,191 @@
+package consul
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/bsonger/devflow-common/client/logging"
+	"github.com/bsonger/devflow-common/model"
+)
+
+type subscriber struct {
+	id int
+	fn func(old, new interface{})
+}
+
+var (
+	subMu       sync.Mutex
+	subscribers = map[string][]subscriber{}
+	nextSubID   int
+
+	// applyMu 保证 更新配置 + 入队 是串行的，订阅者按更新顺序收到变化
+	// 通知在 applyMu 之外进行，同一时间只有一个 goroutine 负责按顺序派发 pending
+	applyMu     sync.Mutex
+	pending     []configChange
+	dispatching bool
+)
+
+// configChange 一次成功更新前后的配置快照
+type configChange struct {
+	old, new *model.Config
+}
+
+// Sections 返回可以订阅的配置段（model.Config 字段的 yaml 名称）
+func Sections() []string {
+	t := reflect.TypeOf(model.Config{})
+	sections := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sections = append(sections, sectionName(t.Field(i)))
+	}
+	return sections
+}
+
+// Subscribe 订阅某个配置段的变化，fn 收到变化前后的值，T 必须与该段的字段类型一致
+// 例如 Subscribe[*model.LogConfig]("log", ...)，返回取消订阅的函数
+// fn 不应再调用 ApplyConfig / ConfigHandler；即使调用也不会死锁，但这次变化要等当前这一轮通知结束后才会送达
+func Subscribe[T any](section string, fn func(old, new T)) (func(), error) {
+	field, ok := sectionField(section)
+	if !ok {
+		return nil, fmt.Errorf("unknown config section %q", section)
+	}
+	if want := reflect.TypeOf((*T)(nil)).Elem(); field.Type != want {
+		return nil, fmt.Errorf("config section %q is %s, not %s", section, field.Type, want)
+	}
+
+	subMu.Lock()
+	defer subMu.Unlock()
+	nextSubID++
+	id := nextSubID
+	subscribers[section] = append(subscribers[section], subscriber{
+		id: id,
+		fn: func(old, new interface{}) { fn(old.(T), new.(T)) },
+	})
+
+	return func() {
+		subMu.Lock()
+		defer subMu.Unlock()
+		subs := subscribers[section]
+		for i, s := range subs {
+			if s.id == id {
+				subscribers[section] = append(subs[:i:i], subs[i+1:]...)
+				return
+			}
+		}
+	}, nil
+}
+
+// ApplyConfig 通过 model.UpdateConfig 把 newCfg 合并到当前配置的副本上，校验通过后再整体替换，
+// 最后只通知真正发生变化的配置段；校验失败时保留原配置并返回错误
+// newCfg 中的零值视为未设置，需要显式置零时请使用 ConfigHandler 传入原始文档
+func ApplyConfig(newCfg *model.Config) error {
+	return apply(func(c *model.Config) error {
+		MergeConfig(c, newCfg)
+		return nil
+	})
+}
+
+// apply 更新配置并按更新顺序通知订阅者
+// 已经有 goroutine 在派发通知时（包括订阅者回调中再次调用），只入队，由它继续派发后返回
+func apply(merge func(c *model.Config) error) error {
+	applyMu.Lock()
+	old, merged, err := model.UpdateConfig(func(c *model.Config) error {
+		if err := merge(c); err != nil {
+			return err
+		}
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		applyMu.Unlock()
+		recordFailure(err)
+		return err
+	}
+	recordSuccess()
+
+	pending = append(pending, configChange{old: old, new: merged})
+	if dispatching {
+		applyMu.Unlock()
+		return nil
+	}
+	dispatching = true
+	applyMu.Unlock()
+
+	dispatch()
+	return nil
+}
+
+// dispatch 按顺序通知 pending 中的变化，直到队列为空
+func dispatch() {
+	for {
+		applyMu.Lock()
+		if len(pending) == 0 {
+			dispatching = false
+			applyMu.Unlock()
+			return
+		}
+		change := pending[0]
+		pending = pending[1:]
+		applyMu.Unlock()
+
+		notify(change.old, change.new)
+	}
+}
+
+func notify(old, new *model.Config) {
+	ov := reflect.ValueOf(old)
+	nv := reflect.ValueOf(new).Elem()
+	t := nv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		section := sectionName(t.Field(i))
+		newVal := nv.Field(i)
+		oldVal := reflect.Zero(t.Field(i).Type)
+		if !ov.IsNil() {
+			oldVal = ov.Elem().Field(i)
+		}
+		if reflect.DeepEqual(oldVal.Interface(), newVal.Interface()) {
+			continue
+		}
+
+		subMu.Lock()
+		subs := append([]subscriber(nil), subscribers[section]...)
+		subMu.Unlock()
+
+		for _, s := range subs {
+			callSubscriber(section, s, oldVal.Interface(), newVal.Interface())
+		}
+	}
+}
+
+func callSubscriber(section string, s subscriber, old, new interface{}) {
+	defer func() {
+		if r := recover(); r != nil && logging.Logger != nil {
+			logging.Logger.Error("config subscriber panicked", zap.String("section", section), zap.Any("panic", r))
+		}
+	}()
+	s.fn(old, new)
+}
+
+func sectionField(section string) (reflect.StructField, bool) {
+	t := reflect.TypeOf(model.Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if sectionName(t.Field(i)) == section {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func sectionName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
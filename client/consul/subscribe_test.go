@@ -0,0 +1,49 @@
+package consul
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+func TestSubscriberCanApplyConfig(t *testing.T) {
+	model.SetConfig(&model.Config{Log: &model.LogConfig{Level: "info", Format: "json"}})
+	t.Cleanup(func() { model.SetConfig(nil) })
+
+	var levels []string
+	unsubscribe, err := Subscribe("log", func(_, new *model.LogConfig) {
+		levels = append(levels, new.Level)
+		// 订阅者在回调中归一化自己的配置段
+		if new.Level == "DEBUG" {
+			if err := ApplyConfig(&model.Config{Log: &model.LogConfig{Level: "debug"}}); err != nil {
+				t.Errorf("ApplyConfig from subscriber: %v", err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(unsubscribe)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ApplyConfig(&model.Config{Log: &model.LogConfig{Level: "DEBUG"}})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ApplyConfig deadlocked when a subscriber applied config")
+	}
+
+	if want := []string{"DEBUG", "debug"}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("notified levels = %v, want %v in update order", levels, want)
+	}
+	if got := model.Current().Log.Level; got != "debug" {
+		t.Errorf("log.level = %q, want debug", got)
+	}
+}
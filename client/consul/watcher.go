@@ -0,0 +1,195 @@
+package consul
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	watchWaitTime = 60 * time.Second // 长轮询
+	minBackoff    = 1 * time.Second
+	maxBackoff    = 60 * time.Second
+)
+
+// Handler 处理 key 的新值，返回 error 表示本次重载失败
+type Handler func(value []byte) error
+
+// Watcher 通过 blocking query 监听单个 Consul key 或前缀，每个 Watcher 独立维护 index
+type Watcher struct {
+	client  *api.Client
+	key     string
+	handler Handler
+	logger  *zap.Logger
+
+	// prefix 为 true 时监听 key 前缀，decode 把前缀下的所有 key 转换成交给 handler 的内容
+	prefix bool
+	decode func(pairs api.KVPairs) ([]byte, error)
+
+	index   uint64
+	reloads metric.Int64Counter
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewWatcher(client *api.Client, key string, handler Handler, logger *zap.Logger) *Watcher {
+	reloads, _ := otel.Meter("devflow-common/consul").Int64Counter(
+		"devflow.consul.config.reloads",
+		metric.WithDescription("Consul config reloads by result"),
+	)
+	return &Watcher{
+		client:  client,
+		key:     key,
+		handler: handler,
+		logger:  logger,
+		reloads: reloads,
+		decode: func(pairs api.KVPairs) ([]byte, error) {
+			return pairs[0].Value, nil
+		},
+	}
+}
+
+// NewPrefixWatcher 监听 prefix 下每个配置段一个 key 的配置，组装成一个 YAML 文档后交给 handler
+// format 为空时按每个 key 的扩展名或内容识别格式
+func NewPrefixWatcher(client *api.Client, prefix, format string, handler Handler, logger *zap.Logger) *Watcher {
+	w := NewWatcher(client, prefix, handler, logger)
+	w.prefix = true
+	w.decode = func(pairs api.KVPairs) ([]byte, error) {
+		return assemblePrefix(prefix, format, pairs)
+	}
+	return w
+}
+
+// Start 在后台开始监听，重复调用无效；ctx 结束或调用 Stop 时退出
+func (w *Watcher) Start(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		return
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+	go w.run(ctx)
+}
+
+// Stop 停止监听并等待后台 goroutine 退出
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.cancel = nil
+	w.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	kv := w.client.KV()
+	backoff := minBackoff
+
+	for {
+		opts := (&api.QueryOptions{
+			WaitIndex: w.index,
+			WaitTime:  watchWaitTime,
+		}).WithContext(ctx)
+
+		pairs, meta, err := w.fetch(kv, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.logger.Warn("consul watch error", zap.String("key", w.key), zap.Duration("retry_in", backoff), zap.Error(err))
+			if !sleep(ctx, jitter(backoff)) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+
+		// index 回退（例如 Consul 重建、快照恢复）时从头开始
+		if meta.LastIndex < w.index {
+			w.logger.Info("consul index went backwards, resetting", zap.String("key", w.key))
+			w.index = 0
+			continue
+		}
+		if meta.LastIndex == w.index {
+			continue
+		}
+		w.index = max(meta.LastIndex, 1)
+
+		// key 被删除
+		if len(pairs) == 0 {
+			continue
+		}
+
+		w.logger.Info("🟢 侦测到 Consul 配置变化，重新加载", zap.String("key", w.key), zap.Uint64("index", w.index))
+		value, err := w.decode(pairs)
+		if err == nil {
+			err = w.handler(value)
+		}
+		if err != nil {
+			w.record(ctx, false)
+			w.logger.Error("consul config reload failed", zap.String("key", w.key), zap.Error(err))
+			continue
+		}
+		w.record(ctx, true)
+	}
+}
+
+func (w *Watcher) fetch(kv *api.KV, opts *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	if w.prefix {
+		return kv.List(w.key, opts)
+	}
+	pair, meta, err := kv.Get(w.key, opts)
+	if err != nil || pair == nil {
+		return nil, meta, err
+	}
+	return api.KVPairs{pair}, meta, nil
+}
+
+func (w *Watcher) record(ctx context.Context, ok bool) {
+	if w.reloads == nil {
+		return
+	}
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+	w.reloads.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(
+		attribute.String("key", w.key),
+		attribute.String("result", result),
+	))
+}
+
+// jitter 在 [d/2, d) 之间随机
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -2,7 +2,6 @@ package otel
 
 import (
 	"context"
-	"os"
 	"time"
 
 	"github.com/bsonger/devflow-common/client/logging"
@@ -33,7 +32,7 @@ func InitOtel(ctx context.Context, config *model.OtelConfig) (func(context.Conte
 		resource.WithAttributes(
 			semconv.ServiceName(config.ServiceName),
 			semconv.ServiceNamespace("app"),
-			semconv.DeploymentEnvironmentName(os.Getenv("ENV")),
+			semconv.DeploymentEnvironmentName(model.Environment()),
 		),
 	)
 	if err != nil {
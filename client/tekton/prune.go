@@ -0,0 +1,214 @@
+package tekton
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bsonger/devflow-common/client/mongo"
+	"github.com/bsonger/devflow-common/model"
+)
+
+// PrunePolicy PipelineRun 历史保留策略
+type PrunePolicy struct {
+	Namespace string
+	// KeepLast 每个 application 至少保留最近的 N 个 PipelineRun
+	KeepLast int
+	// MaxAge 超出 KeepLast 的 PipelineRun 结束超过 MaxAge 才会删除，0 表示立即删除
+	MaxAge time.Duration
+	// DryRun 只记录不删除
+	DryRun bool
+}
+
+// ProtectedFunc 返回不能删除的 manifest（ID hex 或 manifest name）
+type ProtectedFunc func(ctx context.Context) (map[string]bool, error)
+
+// PrunedRun 被删除的 PipelineRun
+type PrunedRun struct {
+	Name           string    `json:"name"`
+	Application    string    `json:"application"`
+	Manifest       string    `json:"manifest"`
+	CompletionTime time.Time `json:"completion_time"`
+}
+
+// PruneResult 一次清理的结果
+type PruneResult struct {
+	Deleted []PrunedRun `json:"deleted"`
+	Kept    int         `json:"kept"`
+	Errors  []error     `json:"-"`
+}
+
+type Pruner struct {
+	policy    PrunePolicy
+	protected ProtectedFunc
+	logger    *zap.Logger
+}
+
+// NewPruner protected 为 nil 时使用 ActiveManifests（从 mongo 读取各 Application 生效的 manifest）
+func NewPruner(policy PrunePolicy, protected ProtectedFunc, logger *zap.Logger) *Pruner {
+	if protected == nil {
+		protected = ActiveManifests
+	}
+	return &Pruner{
+		policy:    policy,
+		protected: protected,
+		logger:    logger,
+	}
+}
+
+// ActiveManifests 返回所有 Application 在各环境中正在使用的 manifest
+func ActiveManifests(ctx context.Context) (map[string]bool, error) {
+	var apps []model.Application
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
+	if err := mongo.Repo.List(ctx, &model.Application{}, filter, &apps); err != nil {
+		return nil, err
+	}
+
+	active := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		if app.ActiveManifestID != nil {
+			active[app.ActiveManifestID.Hex()] = true
+		}
+		if app.ActiveManifestName != "" {
+			active[app.ActiveManifestName] = true
+		}
+		for _, state := range app.Environments {
+			active[state.ManifestID.Hex()] = true
+			if state.ManifestName != "" {
+				active[state.ManifestName] = true
+			}
+		}
+	}
+	return active, nil
+}
+
+// DefaultPruneInterval Run 的 interval 不大于 0 时使用的清理间隔
+const DefaultPruneInterval = 10 * time.Minute
+
+// Run 周期性执行清理，直到 ctx 结束；interval 不大于 0 时使用 DefaultPruneInterval
+func (p *Pruner) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Prune(ctx); err != nil {
+			p.logger.Error("pipelinerun prune failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune 执行一次清理
+func (p *Pruner) Prune(ctx context.Context) (*PruneResult, error) {
+	protected, err := p.protected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := TektonClient.TektonV1().PipelineRuns(p.policy.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// 按 application 分组
+	groups := map[string][]*tknv1.PipelineRun{}
+	for i := range list.Items {
+		pr := &list.Items[i]
+		app := pipelineRunApplication(pr)
+		if app == "" {
+			continue
+		}
+		groups[app] = append(groups[app], pr)
+	}
+
+	result := &PruneResult{}
+	now := time.Now()
+	for app, runs := range groups {
+		// 新的在前
+		sort.Slice(runs, func(i, j int) bool {
+			return runs[j].CreationTimestamp.Before(&runs[i].CreationTimestamp)
+		})
+
+		for i, pr := range runs {
+			if i < p.policy.KeepLast || !pr.IsDone() || pr.Status.CompletionTime == nil {
+				result.Kept++
+				continue
+			}
+			if p.policy.MaxAge > 0 && now.Sub(pr.Status.CompletionTime.Time) < p.policy.MaxAge {
+				result.Kept++
+				continue
+			}
+			id, name := pipelineRunManifest(pr)
+			if protected[id] || protected[name] {
+				result.Kept++
+				continue
+			}
+
+			if !p.policy.DryRun {
+				if err := deletePipelineRun(ctx, pr); err != nil {
+					result.Errors = append(result.Errors, err)
+					p.logger.Warn("delete pipelinerun failed", zap.String("name", pr.Name), zap.Error(err))
+					continue
+				}
+			}
+
+			pruned := PrunedRun{
+				Name:           pr.Name,
+				Application:    app,
+				Manifest:       name,
+				CompletionTime: pr.Status.CompletionTime.Time,
+			}
+			result.Deleted = append(result.Deleted, pruned)
+			p.logger.Info("pipelinerun pruned",
+				zap.String("name", pruned.Name),
+				zap.String("application", pruned.Application),
+				zap.String("manifest", pruned.Manifest),
+				zap.Time("completion_time", pruned.CompletionTime),
+				zap.Bool("dry_run", p.policy.DryRun),
+			)
+		}
+	}
+
+	return result, nil
+}
+
+func deletePipelineRun(ctx context.Context, pr *tknv1.PipelineRun) error {
+	// TaskRun / PVC 通过 OwnerReference 级联删除
+	policy := metav1.DeletePropagationBackground
+	return TektonClient.TektonV1().PipelineRuns(pr.Namespace).Delete(ctx, pr.Name, metav1.DeleteOptions{
+		PropagationPolicy: &policy,
+	})
+}
+
+// pipelineRunApplication 优先读 label，兼容旧的 PipelineRun 读 name 参数
+func pipelineRunApplication(pr *tknv1.PipelineRun) string {
+	if app := pr.Labels[model.ApplicationLabel]; app != "" {
+		return app
+	}
+	return paramString(pr, "name")
+}
+
+func pipelineRunManifest(pr *tknv1.PipelineRun) (id, name string) {
+	return pr.Labels[model.ManifestIDLabel], paramString(pr, "manifest-name")
+}
+
+func paramString(pr *tknv1.PipelineRun, name string) string {
+	for _, p := range pr.Spec.Params {
+		if p.Name == name {
+			return p.Value.StringVal
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package tekton
+
+import (
+	"strings"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+// Pipeline / Task 约定输出的 result 名称
+const (
+	ResultImageURL    = "IMAGE_URL"
+	ResultImageDigest = "IMAGE_DIGEST"
+	ResultCommit      = "commit"
+)
+
+// ResultNames 描述 result 名称到 Manifest 字段的映射，不同 Pipeline 可以自定义
+type ResultNames struct {
+	ImageURL    string
+	ImageDigest string
+	Commit      string
+}
+
+// DefaultResultNames 默认的 result 名称
+var DefaultResultNames = ResultNames{
+	ImageURL:    ResultImageURL,
+	ImageDigest: ResultImageDigest,
+	Commit:      ResultCommit,
+}
+
+// PipelineRunResults 返回 PipelineRun 的 results（name -> value）
+func PipelineRunResults(pr *tknv1.PipelineRun) map[string]string {
+	results := make(map[string]string, len(pr.Status.Results))
+	for _, r := range pr.Status.Results {
+		results[r.Name] = resultString(r.Value)
+	}
+	return results
+}
+
+// TaskRunResults 返回 TaskRun 的 results（name -> value）
+func TaskRunResults(tr *tknv1.TaskRun) map[string]string {
+	results := make(map[string]string, len(tr.Status.Results))
+	for _, r := range tr.Status.Results {
+		results[r.Name] = resultString(r.Value)
+	}
+	return results
+}
+
+// ApplyPipelineRunResults 在 PipelineRun 成功结束后，把 results 写回 Manifest
+// 返回 Manifest 是否有字段被修改
+func ApplyPipelineRunResults(m *model.Manifest, pr *tknv1.PipelineRun, names ResultNames) bool {
+	if !pr.IsDone() || !pr.IsSuccessful() {
+		return false
+	}
+	return applyResults(m, PipelineRunResults(pr), names)
+}
+
+// ApplyTaskRunResults 在 TaskRun 成功结束后，把 results 写回 Manifest
+// 适用于 Pipeline 没有声明 pipeline 级别 results 的情况
+func ApplyTaskRunResults(m *model.Manifest, tr *tknv1.TaskRun, names ResultNames) bool {
+	if !tr.IsDone() || !tr.IsSuccessful() {
+		return false
+	}
+	return applyResults(m, TaskRunResults(tr), names)
+}
+
+func applyResults(m *model.Manifest, results map[string]string, names ResultNames) bool {
+	changed := false
+	set := func(dst *string, name string) {
+		if name == "" {
+			return
+		}
+		v, ok := results[name]
+		if !ok || v == "" || *dst == v {
+			return
+		}
+		*dst = v
+		changed = true
+	}
+
+	set(&m.ImageRef, names.ImageURL)
+	set(&m.ImageDigest, names.ImageDigest)
+	set(&m.CommitSHA, names.Commit)
+	return changed
+}
+
+func resultString(v tknv1.ResultValue) string {
+	switch v.Type {
+	case tknv1.ParamTypeArray:
+		return strings.Join(v.ArrayVal, ",")
+	case tknv1.ParamTypeObject:
+		return ""
+	}
+	return strings.TrimSpace(v.StringVal)
+}
@@ -12,27 +12,48 @@ import (
 	"k8s.io/client-go/rest"
 )
 
-var TektonClient *tektonclient.Clientset
-var KubeClient *kubernetes.Clientset
+// TektonClient / KubeClient 使用接口类型，测试时可以注入 fake clientset
+var TektonClient tektonclient.Interface
+var KubeClient kubernetes.Interface
 
 func InitTektonClient(ctx context.Context, config *rest.Config, logger *zap.Logger) error {
-
-	var err error
-	TektonClient, err = tektonclient.NewForConfig(config)
-	KubeClient, err = kubernetes.NewForConfig(config)
+	tekton, kube, err := NewClients(config)
 	if err != nil {
 		return err
 	}
+	SetClients(tekton, kube)
 	logger.Info(fmt.Sprintf("tekton client initialized"))
 	return nil
 }
 
+// NewClients 根据 rest.Config 创建 tekton 与 kubernetes client
+func NewClients(config *rest.Config) (tektonclient.Interface, kubernetes.Interface, error) {
+	tekton, err := tektonclient.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create tekton client: %w", err)
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return tekton, kube, nil
+}
+
+// SetClients 注入 client，例如测试中的 fake clientset
+func SetClients(tekton tektonclient.Interface, kube kubernetes.Interface) {
+	TektonClient = tekton
+	KubeClient = kube
+}
+
 func GetPipeline(ctx context.Context, namespace string, name string) (*v1.Pipeline, error) {
 	return TektonClient.TektonV1().Pipelines(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
 func CreatePipelineRun(ctx context.Context, namespace string, pr *tknv1.PipelineRun) (*tknv1.PipelineRun, error) {
 
+	// 把调用方的 trace 写入 annotations，串联 API 请求与流水线
+	InjectTraceContext(ctx, pr)
+
 	// 创建 PipelineRun
 	created, err := TektonClient.TektonV1().PipelineRuns(namespace).Create(ctx, pr, metav1.CreateOptions{})
 	if err != nil {
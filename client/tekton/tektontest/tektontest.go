@@ -0,0 +1,202 @@
+// Package tektontest 基于生成的 fake clientset 提供 client/tekton 的测试工具，
+// 可以模拟 PipelineRun / TaskRun 的执行过程
+package tektontest
+
+import (
+	"context"
+	"time"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	tektonscheme "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"knative.dev/pkg/apis"
+
+	"github.com/bsonger/devflow-common/client/tekton"
+)
+
+// Harness 持有 fake clientset，并已注入到 client/tekton
+type Harness struct {
+	Tekton *tektonfake.Clientset
+	Kube   *kubefake.Clientset
+}
+
+// New 创建 fake clientset 并注入 client/tekton
+// objects 中 tekton.dev 的对象放入 tekton clientset，其余放入 kubernetes clientset
+func New(objects ...runtime.Object) *Harness {
+	var tektonObjs, kubeObjs []runtime.Object
+	for _, obj := range objects {
+		if isTektonObject(obj) {
+			tektonObjs = append(tektonObjs, obj)
+		} else {
+			kubeObjs = append(kubeObjs, obj)
+		}
+	}
+
+	h := &Harness{
+		Tekton: tektonfake.NewSimpleClientset(tektonObjs...),
+		Kube:   kubefake.NewSimpleClientset(kubeObjs...),
+	}
+	// fake clientset 不处理 generateName，这里补上
+	h.Tekton.PrependReactor("create", "*", generateNameReactor)
+	h.Kube.PrependReactor("create", "*", generateNameReactor)
+
+	tekton.SetClients(h.Tekton, h.Kube)
+	return h
+}
+
+// StartPipelineRun 把 PipelineRun 置为运行中
+func (h *Harness) StartPipelineRun(ctx context.Context, namespace, name string) (*tknv1.PipelineRun, error) {
+	pr, err := h.getPipelineRun(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	now := metav1.NewTime(time.Now())
+	pr.Status.StartTime = &now
+	pr.Status.SetCondition(&apis.Condition{
+		Type:   apis.ConditionSucceeded,
+		Status: corev1.ConditionUnknown,
+		Reason: tknv1.PipelineRunReasonRunning.String(),
+	})
+	return h.updatePipelineRun(ctx, pr)
+}
+
+// StartTaskRun 为 PipelineRun 的某个 pipelineTask 创建一个运行中的 TaskRun
+func (h *Harness) StartTaskRun(ctx context.Context, namespace, pipelineRun, pipelineTask string) (*tknv1.TaskRun, error) {
+	pr, err := h.getPipelineRun(ctx, namespace, pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.NewTime(time.Now())
+	tr := &tknv1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pipelineRun + "-" + pipelineTask,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"tekton.dev/pipelineRun":  pipelineRun,
+				"tekton.dev/pipelineTask": pipelineTask,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(pr, tknv1.SchemeGroupVersion.WithKind("PipelineRun")),
+			},
+		},
+	}
+	tr.Status.StartTime = &now
+	tr.Status.SetCondition(&apis.Condition{
+		Type:   apis.ConditionSucceeded,
+		Status: corev1.ConditionUnknown,
+		Reason: tknv1.TaskRunReasonRunning.String(),
+	})
+
+	created, err := h.Tekton.TektonV1().TaskRuns(namespace).Create(ctx, tr, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	pr.Status.ChildReferences = append(pr.Status.ChildReferences, tknv1.ChildStatusReference{
+		TypeMeta:         runtime.TypeMeta{APIVersion: tknv1.SchemeGroupVersion.String(), Kind: "TaskRun"},
+		Name:             created.Name,
+		PipelineTaskName: pipelineTask,
+	})
+	if _, err := h.updatePipelineRun(ctx, pr); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// CompleteTaskRun 结束 TaskRun，并写入 results
+func (h *Harness) CompleteTaskRun(ctx context.Context, namespace, name string, succeeded bool, results map[string]string) (*tknv1.TaskRun, error) {
+	tr, err := h.Tekton.TektonV1().TaskRuns(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.NewTime(time.Now())
+	tr.Status.CompletionTime = &now
+	for k, v := range results {
+		tr.Status.Results = append(tr.Status.Results, tknv1.TaskRunResult{
+			Name:  k,
+			Type:  tknv1.ResultsTypeString,
+			Value: *tknv1.NewStructuredValues(v),
+		})
+	}
+	tr.Status.SetCondition(doneCondition(succeeded, tknv1.TaskRunReasonSuccessful.String(), tknv1.TaskRunReasonFailed.String()))
+
+	return h.Tekton.TektonV1().TaskRuns(namespace).UpdateStatus(ctx, tr, metav1.UpdateOptions{})
+}
+
+// CompletePipelineRun 结束 PipelineRun，并写入 results
+func (h *Harness) CompletePipelineRun(ctx context.Context, namespace, name string, succeeded bool, results map[string]string) (*tknv1.PipelineRun, error) {
+	pr, err := h.getPipelineRun(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.NewTime(time.Now())
+	if pr.Status.StartTime == nil {
+		pr.Status.StartTime = &now
+	}
+	pr.Status.CompletionTime = &now
+	for k, v := range results {
+		pr.Status.Results = append(pr.Status.Results, tknv1.PipelineRunResult{
+			Name:  k,
+			Value: *tknv1.NewStructuredValues(v),
+		})
+	}
+	pr.Status.SetCondition(doneCondition(succeeded, tknv1.PipelineRunReasonSuccessful.String(), tknv1.PipelineRunReasonFailed.String()))
+
+	return h.updatePipelineRun(ctx, pr)
+}
+
+func (h *Harness) getPipelineRun(ctx context.Context, namespace, name string) (*tknv1.PipelineRun, error) {
+	return h.Tekton.TektonV1().PipelineRuns(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (h *Harness) updatePipelineRun(ctx context.Context, pr *tknv1.PipelineRun) (*tknv1.PipelineRun, error) {
+	return h.Tekton.TektonV1().PipelineRuns(pr.Namespace).UpdateStatus(ctx, pr, metav1.UpdateOptions{})
+}
+
+func doneCondition(succeeded bool, okReason, failReason string) *apis.Condition {
+	if succeeded {
+		return &apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: okReason}
+	}
+	return &apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: failReason}
+}
+
+func isTektonObject(obj runtime.Object) bool {
+	kinds, _, err := tektonscheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return false
+	}
+	for _, k := range kinds {
+		if k.Group == tknv1.SchemeGroupVersion.Group {
+			return true
+		}
+	}
+	return false
+}
+
+func generateNameReactor(action k8stesting.Action) (bool, runtime.Object, error) {
+	create, ok := action.(k8stesting.CreateAction)
+	if !ok {
+		return false, nil, nil
+	}
+	obj, err := meta.Accessor(create.GetObject())
+	if err != nil {
+		return false, nil, nil
+	}
+	if obj.GetName() == "" && obj.GetGenerateName() != "" {
+		obj.SetName(obj.GetGenerateName() + utilrand.String(5))
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(action.GetNamespace())
+	}
+	return false, nil, nil
+}
@@ -0,0 +1,114 @@
+package tektontest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bsonger/devflow-common/client/tekton"
+	"github.com/bsonger/devflow-common/client/tekton/tektontest"
+	"github.com/bsonger/devflow-common/model"
+)
+
+const namespace = "ci"
+
+func newManifest() *model.Manifest {
+	m := &model.Manifest{
+		ApplicationName: "demo",
+		Name:            "v1",
+		Branch:          "main",
+		GitRepo:         "https://git.example.com/devflow/demo.git",
+	}
+	m.ID = primitive.NewObjectID()
+	return m
+}
+
+func TestPipelineRunResultsAfterCompletion(t *testing.T) {
+	ctx := context.Background()
+	h := tektontest.New()
+	m := newManifest()
+
+	created, err := tekton.CreatePipelineRun(ctx, namespace, m.GeneratePipelineRun("build", "source-pvc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Name, "build-run-") || created.Namespace != namespace {
+		t.Fatalf("created %s/%s, want generated name in %s", created.Namespace, created.Name, namespace)
+	}
+	if created.Labels[model.ManifestIDLabel] != m.ID.Hex() {
+		t.Errorf("manifest label = %q, want %s", created.Labels[model.ManifestIDLabel], m.ID.Hex())
+	}
+
+	if _, err := h.StartPipelineRun(ctx, namespace, created.Name); err != nil {
+		t.Fatal(err)
+	}
+	running, err := h.Tekton.TektonV1().PipelineRuns(namespace).Get(ctx, created.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running.IsDone() || tekton.ApplyPipelineRunResults(m, running, tekton.DefaultResultNames) {
+		t.Fatal("results applied while the PipelineRun is still running")
+	}
+
+	tr, err := h.StartTaskRun(ctx, namespace, created.Name, "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CompleteTaskRun(ctx, namespace, tr.Name, true, map[string]string{
+		tekton.ResultImageDigest: "sha256:abc",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	done, err := h.CompletePipelineRun(ctx, namespace, created.Name, true, map[string]string{
+		tekton.ResultImageURL:    "registry.example.com/demo:v1",
+		tekton.ResultImageDigest: "sha256:abc",
+		tekton.ResultCommit:      "0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done.Status.ChildReferences) != 1 || done.Status.ChildReferences[0].Name != tr.Name {
+		t.Errorf("child references = %+v, want the started TaskRun", done.Status.ChildReferences)
+	}
+
+	if !tekton.ApplyPipelineRunResults(m, done, tekton.DefaultResultNames) {
+		t.Fatal("ApplyPipelineRunResults reported no change")
+	}
+	if m.ImageRef != "registry.example.com/demo:v1" || m.ImageDigest != "sha256:abc" || m.CommitSHA != "0123456789abcdef" {
+		t.Errorf("manifest = {%s %s %s}, want results applied", m.ImageRef, m.ImageDigest, m.CommitSHA)
+	}
+	if tekton.ApplyPipelineRunResults(m, done, tekton.DefaultResultNames) {
+		t.Error("applying the same results twice reported a change")
+	}
+}
+
+func TestTaskRunResultsIgnoredOnFailure(t *testing.T) {
+	ctx := context.Background()
+	h := tektontest.New()
+	m := newManifest()
+
+	created, err := tekton.CreatePipelineRun(ctx, namespace, m.GeneratePipelineRun("build", "source-pvc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := h.StartTaskRun(ctx, namespace, created.Name, "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := h.CompleteTaskRun(ctx, namespace, tr.Name, false, map[string]string{
+		tekton.ResultImageURL: "registry.example.com/demo:broken",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !failed.IsDone() || failed.IsSuccessful() {
+		t.Fatalf("TaskRun condition = %+v, want failed", failed.Status.Conditions)
+	}
+	if tekton.ApplyTaskRunResults(m, failed, tekton.DefaultResultNames) || m.ImageRef != "" {
+		t.Errorf("results of a failed TaskRun applied: %s", m.ImageRef)
+	}
+}
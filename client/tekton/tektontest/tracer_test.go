@@ -0,0 +1,72 @@
+package tektontest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+
+	"github.com/bsonger/devflow-common/client/tekton"
+	"github.com/bsonger/devflow-common/client/tekton/tektontest"
+)
+
+func TestTaskRunTracerRecordsChildSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h := tektontest.New()
+
+	reqCtx, request := provider.Tracer("test").Start(ctx, "POST /manifests")
+	created, err := tekton.CreatePipelineRun(reqCtx, namespace, newManifest().GeneratePipelineRun("build", "source-pvc"))
+	request.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracerCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- tekton.NewTaskRunTracer(namespace, 0, zap.NewNop()).Run(tracerCtx) }()
+
+	tr, err := h.StartTaskRun(ctx, namespace, created.Name, "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CompleteTaskRun(ctx, namespace, tr.Name, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for !found {
+		for _, span := range recorder.Ended() {
+			if span.Name() != "tekton.task build" {
+				continue
+			}
+			found = true
+			if span.Parent().SpanID() != request.SpanContext().SpanID() || span.SpanContext().TraceID() != request.SpanContext().TraceID() {
+				t.Errorf("span parent = %s/%s, want the request span", span.Parent().TraceID(), span.Parent().SpanID())
+			}
+		}
+		if found {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("no TaskRun span recorded")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	stop()
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package tekton
+
+import (
+	"context"
+	"time"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+
+	"github.com/bsonger/devflow-common/model"
+)
+
+const tracerName = "devflow-common/tekton"
+
+var traceContext = propagation.TraceContext{}
+
+// InjectTraceContext 把 ctx 中的 span context 写入 PipelineRun 的 annotations
+func InjectTraceContext(ctx context.Context, pr *tknv1.PipelineRun) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+
+	if pr.Annotations == nil {
+		pr.Annotations = map[string]string{}
+	}
+	pr.Annotations[model.TraceIDAnnotation] = sc.TraceID().String()
+	pr.Annotations[model.SpanAnnotation] = sc.SpanID().String()
+	if v := carrier.Get("traceparent"); v != "" {
+		pr.Annotations[model.TraceParentAnnotation] = v
+	}
+	if v := carrier.Get("tracestate"); v != "" {
+		pr.Annotations[model.TraceStateAnnotation] = v
+	}
+}
+
+// ExtractTraceContext 从 PipelineRun 的 annotations 中恢复父 span context
+// 优先使用 W3C traceparent，缺失时退回 trace-id / parent-span-id
+func ExtractTraceContext(ctx context.Context, pr *tknv1.PipelineRun) context.Context {
+	ann := pr.GetAnnotations()
+	if ann == nil {
+		return ctx
+	}
+
+	if tp := ann[model.TraceParentAnnotation]; tp != "" {
+		carrier := propagation.MapCarrier{
+			"traceparent": tp,
+			"tracestate":  ann[model.TraceStateAnnotation],
+		}
+		if out := traceContext.Extract(ctx, carrier); trace.SpanContextFromContext(out).IsValid() {
+			return out
+		}
+	}
+
+	traceID, err := trace.TraceIDFromHex(ann[model.TraceIDAnnotation])
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(ann[model.SpanAnnotation])
+	if err != nil {
+		return ctx
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// RecordTaskRunSpan 为已结束的 TaskRun 生成一个子 span，使用 TaskRun 真实的开始/结束时间
+// 由 TaskRunTracer 在 TaskRun 结束时调用一次，也可以由自定义的状态监听调用
+func RecordTaskRunSpan(ctx context.Context, pr *tknv1.PipelineRun, tr *tknv1.TaskRun) {
+	if !tr.IsDone() || tr.Status.StartTime == nil {
+		return
+	}
+
+	ctx = ExtractTraceContext(ctx, pr)
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	taskName := tr.Labels["tekton.dev/pipelineTask"]
+	if taskName == "" {
+		taskName = tr.Name
+	}
+
+	_, span := otel.Tracer(tracerName).Start(ctx, "tekton.task "+taskName,
+		trace.WithTimestamp(tr.Status.StartTime.Time),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("tekton.pipelinerun", pr.Name),
+			attribute.String("tekton.taskrun", tr.Name),
+			attribute.String("tekton.pipeline_task", taskName),
+			attribute.String("k8s.namespace.name", tr.Namespace),
+		),
+	)
+
+	if tr.IsSuccessful() {
+		span.SetStatus(codes.Ok, "")
+	} else {
+		msg := ""
+		if c := tr.Status.GetCondition(apis.ConditionSucceeded); c != nil {
+			msg = c.Message
+		}
+		span.SetStatus(codes.Error, msg)
+	}
+
+	span.End(trace.WithTimestamp(endTime(tr.Status.CompletionTime, tr.Status.StartTime)))
+}
+
+func endTime(end, start *metav1.Time) time.Time {
+	if end != nil {
+		return end.Time
+	}
+	return start.Time
+}
@@ -0,0 +1,94 @@
+package tekton
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektoninformers "github.com/tektoncd/pipeline/pkg/client/informers/externalversions"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// pipelineRunLabel Tekton 写在 TaskRun 上的所属 PipelineRun 名称
+const pipelineRunLabel = "tekton.dev/pipelineRun"
+
+// TaskRunTracer 监听属于 PipelineRun 的 TaskRun，在 TaskRun 结束时调用 RecordTaskRunSpan，
+// 使每个 pipeline 步骤作为子 span 挂在 CreatePipelineRun 调用方的 trace 下
+type TaskRunTracer struct {
+	logger   *zap.Logger
+	factory  tektoninformers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+	started  time.Time
+}
+
+// NewTaskRunTracer namespace 为空时监听所有 namespace
+func NewTaskRunTracer(namespace string, resync time.Duration, logger *zap.Logger) *TaskRunTracer {
+	factory := tektoninformers.NewSharedInformerFactoryWithOptions(TektonClient, resync,
+		tektoninformers.WithNamespace(namespace),
+		tektoninformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = pipelineRunLabel
+		}),
+	)
+	return &TaskRunTracer{
+		logger:   logger,
+		factory:  factory,
+		informer: factory.Tekton().V1().TaskRuns().Informer(),
+	}
+}
+
+// Run 启动 informer 并阻塞直到 ctx 结束
+// 启动前已经结束的 TaskRun 不会再生成 span，避免重启后重复上报
+func (t *TaskRunTracer) Run(ctx context.Context) error {
+	t.started = time.Now()
+	if _, err := t.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if tr, ok := obj.(*tknv1.TaskRun); ok && completedSince(tr, t.started) {
+				t.record(ctx, tr)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok1 := oldObj.(*tknv1.TaskRun)
+			tr, ok2 := newObj.(*tknv1.TaskRun)
+			if ok1 && ok2 && !old.IsDone() && tr.IsDone() {
+				t.record(ctx, tr)
+			}
+		},
+	}); err != nil {
+		return err
+	}
+
+	t.factory.Start(ctx.Done())
+	defer t.factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), t.informer.HasSynced) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("tekton taskrun informer cache not synced")
+	}
+	t.logger.Info("tekton taskrun tracer started")
+
+	<-ctx.Done()
+	return nil
+}
+
+func (t *TaskRunTracer) record(ctx context.Context, tr *tknv1.TaskRun) {
+	name := tr.Labels[pipelineRunLabel]
+	pr, err := TektonClient.TektonV1().PipelineRuns(tr.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.logger.Warn("get pipelinerun for taskrun span failed",
+			zap.String("taskrun", tr.Name), zap.String("pipelinerun", name), zap.Error(err))
+		return
+	}
+	RecordTaskRunSpan(ctx, pr, tr)
+}
+
+func completedSince(tr *tknv1.TaskRun, since time.Time) bool {
+	if !tr.IsDone() || tr.Status.CompletionTime == nil {
+		return false
+	}
+	// metav1.Time 只精确到秒
+	return !tr.Status.CompletionTime.Time.Before(since.Truncate(time.Second))
+}
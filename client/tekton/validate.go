@@ -0,0 +1,137 @@
+package tekton
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// ParamTypeMismatch 参数类型与 Pipeline 声明不一致
+type ParamTypeMismatch struct {
+	Name     string          `json:"name"`
+	Expected tknv1.ParamType `json:"expected"`
+	Actual   tknv1.ParamType `json:"actual"`
+}
+
+// ValidationReport PipelineRun 预检结果
+// UnknownParams / UnknownWorkspaces 只是警告：Tekton 只校验 Pipeline 声明过的 params 和 workspaces，多传的会被忽略
+type ValidationReport struct {
+	Pipeline          string              `json:"pipeline"`
+	MissingParams     []string            `json:"missing_params,omitempty"`     // 声明了、没有默认值、也没有传
+	UnknownParams     []string            `json:"unknown_params,omitempty"`     // 传了但 Pipeline 没有声明（警告）
+	TypeMismatches    []ParamTypeMismatch `json:"type_mismatches,omitempty"`    // 类型不一致
+	UnboundWorkspaces []string            `json:"unbound_workspaces,omitempty"` // 声明了（非 optional）但没有绑定
+	UnknownWorkspaces []string            `json:"unknown_workspaces,omitempty"` // 绑定了但 Pipeline 没有声明（警告）
+}
+
+// Valid 是否通过校验，不包括警告
+func (r *ValidationReport) Valid() bool {
+	return len(r.MissingParams) == 0 &&
+		len(r.TypeMismatches) == 0 &&
+		len(r.UnboundWorkspaces) == 0
+}
+
+// Warnings 不影响运行、但可能是配置错误的问题
+func (r *ValidationReport) Warnings() []string {
+	var warnings []string
+	if len(r.UnknownParams) > 0 {
+		warnings = append(warnings, "unknown params: "+strings.Join(r.UnknownParams, ", "))
+	}
+	if len(r.UnknownWorkspaces) > 0 {
+		warnings = append(warnings, "unknown workspaces: "+strings.Join(r.UnknownWorkspaces, ", "))
+	}
+	return warnings
+}
+
+// Err 未通过校验时返回描述性错误，否则返回 nil
+func (r *ValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+
+	var problems []string
+	if len(r.MissingParams) > 0 {
+		problems = append(problems, "missing params: "+strings.Join(r.MissingParams, ", "))
+	}
+	for _, m := range r.TypeMismatches {
+		problems = append(problems, fmt.Sprintf("param %s: expected %s, got %s", m.Name, m.Expected, m.Actual))
+	}
+	if len(r.UnboundWorkspaces) > 0 {
+		problems = append(problems, "unbound workspaces: "+strings.Join(r.UnboundWorkspaces, ", "))
+	}
+	return fmt.Errorf("pipeline %s: %s", r.Pipeline, strings.Join(problems, "; "))
+}
+
+// ValidatePipelineRun 创建 PipelineRun 之前的预检：拉取引用的 Pipeline，校验 params 和 workspaces
+// Pipeline 不存在等请求错误通过 error 返回，校验问题通过 report 返回
+func ValidatePipelineRun(ctx context.Context, namespace string, pr *tknv1.PipelineRun) (*ValidationReport, error) {
+	if pr.Spec.PipelineRef == nil || pr.Spec.PipelineRef.Name == "" {
+		return nil, fmt.Errorf("pipelinerun has no pipelineRef")
+	}
+
+	pipeline, err := GetPipeline(ctx, namespace, pr.Spec.PipelineRef.Name)
+	if err != nil {
+		return nil, fmt.Errorf("get pipeline %s: %w", pr.Spec.PipelineRef.Name, err)
+	}
+
+	return CheckPipelineRun(pipeline, pr), nil
+}
+
+// CheckPipelineRun 校验 PipelineRun 与 Pipeline 声明是否匹配（不访问集群）
+func CheckPipelineRun(pipeline *tknv1.Pipeline, pr *tknv1.PipelineRun) *ValidationReport {
+	report := &ValidationReport{Pipeline: pipeline.Name}
+
+	// params
+	declared := make(map[string]tknv1.ParamSpec, len(pipeline.Spec.Params))
+	for _, p := range pipeline.Spec.Params {
+		declared[p.Name] = p
+	}
+	provided := make(map[string]tknv1.Param, len(pr.Spec.Params))
+	for _, p := range pr.Spec.Params {
+		provided[p.Name] = p
+
+		spec, ok := declared[p.Name]
+		if !ok {
+			report.UnknownParams = append(report.UnknownParams, p.Name)
+			continue
+		}
+		expected := spec.Type
+		if expected == "" {
+			expected = tknv1.ParamTypeString
+		}
+		if p.Value.Type != expected {
+			report.TypeMismatches = append(report.TypeMismatches, ParamTypeMismatch{
+				Name:     p.Name,
+				Expected: expected,
+				Actual:   p.Value.Type,
+			})
+		}
+	}
+	for _, spec := range pipeline.Spec.Params {
+		if _, ok := provided[spec.Name]; !ok && spec.Default == nil {
+			report.MissingParams = append(report.MissingParams, spec.Name)
+		}
+	}
+
+	// workspaces
+	bound := make(map[string]bool, len(pr.Spec.Workspaces))
+	for _, w := range pr.Spec.Workspaces {
+		bound[w.Name] = true
+	}
+	declaredWs := make(map[string]bool, len(pipeline.Spec.Workspaces))
+	for _, w := range pipeline.Spec.Workspaces {
+		declaredWs[w.Name] = true
+		if !w.Optional && !bound[w.Name] {
+			report.UnboundWorkspaces = append(report.UnboundWorkspaces, w.Name)
+		}
+	}
+	for _, w := range pr.Spec.Workspaces {
+		if !declaredWs[w.Name] {
+			report.UnknownWorkspaces = append(report.UnknownWorkspaces, w.Name)
+		}
+	}
+
+	return report
+}
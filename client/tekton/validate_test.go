@@ -0,0 +1,102 @@
+package tekton
+
+import (
+	"reflect"
+	"testing"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPipeline() *tknv1.Pipeline {
+	return &tknv1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "build"},
+		Spec: tknv1.PipelineSpec{
+			Params: tknv1.ParamSpecs{
+				{Name: "git-url"},
+				{Name: "revision", Default: tknv1.NewStructuredValues("main")},
+				{Name: "tags", Type: tknv1.ParamTypeArray},
+			},
+			Workspaces: []tknv1.PipelineWorkspaceDeclaration{
+				{Name: "source"},
+				{Name: "cache", Optional: true},
+			},
+		},
+	}
+}
+
+func testPipelineRun(params tknv1.Params, workspaces ...string) *tknv1.PipelineRun {
+	pr := &tknv1.PipelineRun{Spec: tknv1.PipelineRunSpec{Params: params}}
+	for _, w := range workspaces {
+		pr.Spec.Workspaces = append(pr.Spec.Workspaces, tknv1.WorkspaceBinding{Name: w})
+	}
+	return pr
+}
+
+func stringParam(name, value string) tknv1.Param {
+	return tknv1.Param{Name: name, Value: *tknv1.NewStructuredValues(value)}
+}
+
+func arrayParam(name string, values ...string) tknv1.Param {
+	return tknv1.Param{Name: name, Value: tknv1.ParamValue{Type: tknv1.ParamTypeArray, ArrayVal: values}}
+}
+
+func TestCheckPipelineRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		pr       *tknv1.PipelineRun
+		valid    bool
+		want     ValidationReport
+		warnings []string
+	}{
+		{
+			name:  "all provided",
+			pr:    testPipelineRun(tknv1.Params{stringParam("git-url", "u"), arrayParam("tags", "a", "b")}, "source"),
+			valid: true,
+			want:  ValidationReport{Pipeline: "build"},
+		},
+		{
+			name:  "missing required param and workspace",
+			pr:    testPipelineRun(tknv1.Params{arrayParam("tags", "a")}),
+			valid: false,
+			want:  ValidationReport{Pipeline: "build", MissingParams: []string{"git-url"}, UnboundWorkspaces: []string{"source"}},
+		},
+		{
+			name:  "type mismatch",
+			pr:    testPipelineRun(tknv1.Params{arrayParam("git-url", "u"), stringParam("tags", "a")}, "source"),
+			valid: false,
+			want: ValidationReport{Pipeline: "build", TypeMismatches: []ParamTypeMismatch{
+				{Name: "git-url", Expected: tknv1.ParamTypeString, Actual: tknv1.ParamTypeArray},
+				{Name: "tags", Expected: tknv1.ParamTypeArray, Actual: tknv1.ParamTypeString},
+			}},
+		},
+		{
+			name: "unknown params and workspaces are warnings",
+			pr: testPipelineRun(tknv1.Params{
+				stringParam("git-url", "u"), arrayParam("tags", "a"), stringParam("manifest-name", "v1"),
+			}, "source", "extra"),
+			valid: true,
+			want: ValidationReport{
+				Pipeline:          "build",
+				UnknownParams:     []string{"manifest-name"},
+				UnknownWorkspaces: []string{"extra"},
+			},
+			warnings: []string{"unknown params: manifest-name", "unknown workspaces: extra"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := CheckPipelineRun(testPipeline(), tt.pr)
+			if !reflect.DeepEqual(*report, tt.want) {
+				t.Errorf("report = %+v, want %+v", *report, tt.want)
+			}
+			if report.Valid() != tt.valid || (report.Err() == nil) != tt.valid {
+				t.Errorf("valid = %v, err = %v, want valid %v", report.Valid(), report.Err(), tt.valid)
+			}
+			if !reflect.DeepEqual(report.Warnings(), tt.warnings) {
+				t.Errorf("warnings = %v, want %v", report.Warnings(), tt.warnings)
+			}
+		})
+	}
+}
@@ -2,6 +2,14 @@ package model
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+const (
+	ApplicationRunning  = "Running"
+	ApplicationFailed   = "Failed"
+	ApplicationDegraded = "Degraded"
+	ApplicationDeleting = "Deleting"
+	ApplicationDeleted  = "Deleted"
+)
+
 type Application struct {
 	BaseModel `bson:",inline"`
 
@@ -16,6 +24,8 @@ type Application struct {
 	Service            Service             `bson:"service" json:"service"`
 	Internet           Internet            `bson:"internet" json:"internet"`
 	Envs               map[string][]EnvVar `bson:"envs,omitempty" json:"envs,omitempty"`
+	// 每个环境当前生效的 manifest，key 为环境名
+	Environments map[string]*EnvironmentState `bson:"environments,omitempty" json:"environments,omitempty"`
 	// 当前状态（来自 Job 的结果）
 	Status string `bson:"status" json:"status"` // Running / Failed / Degraded
 }
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	DefaultArgoNamespace = "argo-cd"
+	DefaultArgoProject   = "app"
+	DefaultArgoPlugin    = "plugin"
+	DefaultArgoServer    = "https://kubernetes.default.svc"
+)
+
+var argoConfig *ArgoConfig
+
+func InitArgoConfig(c *ArgoConfig) {
+	argoConfig = c
+}
+
+// GetArgoConfig 优先使用 InitArgoConfig 设置的配置，其次是当前配置的 argo 段，都没有时返回默认配置
+func GetArgoConfig() *ArgoConfig {
+	if argoConfig != nil {
+		return argoConfig
+	}
+	if c := Current(); c != nil && c.Argo != nil {
+		return c.Argo
+	}
+	return &ArgoConfig{}
+}
+
+// ArgoNamespace ArgoCD Application 所在的 namespace
+func (c *ArgoConfig) ArgoNamespace() string {
+	if c.Namespace != "" {
+		return c.Namespace
+	}
+	return DefaultArgoNamespace
+}
+
+// Resolve 计算 application 在 env 下的 ArgoCD 目标
+// 优先级：默认值 < ArgoConfig 顶层 < Environments[env] < Applications[app]
+func (c *ArgoConfig) Resolve(app, env string) ArgoTarget {
+	target := ArgoTarget{
+		Project: DefaultArgoProject,
+		Plugin:  DefaultArgoPlugin,
+		Server:  DefaultArgoServer,
+	}
+	target.override(c.ArgoTarget)
+	if t := c.Environments[env]; t != nil {
+		target.override(*t)
+	}
+	if t := c.Applications[app]; t != nil {
+		target.override(*t)
+	}
+	return target
+}
+
+func (t *ArgoTarget) override(o ArgoTarget) {
+	if o.Project != "" {
+		t.Project = o.Project
+	}
+	if o.Plugin != "" {
+		t.Plugin = o.Plugin
+	}
+	if o.DestinationNamespace != "" {
+		t.DestinationNamespace = o.DestinationNamespace
+	}
+	// cluster 名称与 server 地址二选一，后设置的生效
+	if o.Cluster != "" {
+		t.Cluster = o.Cluster
+		t.Server = ""
+	}
+	if o.Server != "" {
+		t.Server = o.Server
+		t.Cluster = ""
+	}
+}
+
+// TargetNamespace project 在 env 下部署的 namespace，不同环境默认使用不同 namespace（<project>-<env>），
+// 避免共用集群时互相覆盖资源；env 为空（未指定环境的旧 Job）时为 project
+func (t ArgoTarget) TargetNamespace(project, env string) string {
+	if t.DestinationNamespace != "" {
+		if env == "" {
+			env = Environment()
+		}
+		return strings.NewReplacer("{project}", project, "{env}", env).Replace(t.DestinationNamespace)
+	}
+	if env == "" {
+		return project
+	}
+	return project + "-" + env
+}
+
+// Environment 当前部署环境，统一来源：配置 env > 环境变量 ENV > 旧的环境变量 Env
+func Environment() string {
+	if c := Current(); c != nil && c.Env != "" {
+		return c.Env
+	}
+	if env := os.Getenv("ENV"); env != "" {
+		return env
+	}
+	return os.Getenv("Env")
+}
+
+// ProjectConfig 返回 project 的 AppProject 设置，Projects 中的非空字段覆盖 ProjectDefaults
+func (c *ArgoConfig) ProjectConfig(project string) ArgoProjectConfig {
+	var cfg ArgoProjectConfig
+	if c.ProjectDefaults != nil {
+		cfg = *c.ProjectDefaults
+	}
+	if o := c.Projects[project]; o != nil {
+		if len(o.SourceRepos) > 0 {
+			cfg.SourceRepos = o.SourceRepos
+		}
+		if len(o.Namespaces) > 0 {
+			cfg.Namespaces = o.Namespaces
+		}
+		if len(o.ClusterResourceWhitelist) > 0 {
+			cfg.ClusterResourceWhitelist = o.ClusterResourceWhitelist
+		}
+		if len(o.SyncWindows) > 0 {
+			cfg.SyncWindows = o.SyncWindows
+		}
+	}
+	return cfg
+}
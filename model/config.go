@@ -2,6 +2,9 @@ package model
 
 import "k8s.io/client-go/rest"
 
+// C 启动时加载的配置，只在 SetConfig 中赋值，不会随 UpdateConfig（包括 Consul 热更新）变化
+//
+// Deprecated: 使用 Current() 读取当前配置快照
 var C *Config
 var KubeConfig *rest.Config
 
@@ -12,11 +15,37 @@ type Config struct {
 	Otel   *OtelConfig   `mapstructure:"otel"   json:"otel"   yaml:"otel"`
 	Repo   *Repo         `mapstructure:"repo"   json:"repo"   yaml:"repo"`
 	Consul *Consul       `mapstructure:"consul" json:"consul" yaml:"consul"`
+	Argo   *ArgoConfig   `mapstructure:"argo"   json:"argo"   yaml:"argo"`
+	// Release 发布窗口（封网）策略
+	Release *ReleaseConfig `mapstructure:"release" json:"release" yaml:"release"`
+	Env     string         `mapstructure:"env"    json:"env"    yaml:"env"`
+	// Environments 环境晋级顺序，例如 [dev, staging, prod]
+	Environments []string `mapstructure:"environments" json:"environments" yaml:"environments"`
 }
 
 type Consul struct {
 	Address string `mapstructure:"address" json:"address" yaml:"address"`
 	Key     string `mapstructure:"key"     json:"key"     yaml:"key"`
+	// Prefix 设置时从前缀下每个配置段一个 key（例如 <prefix>/mongo、<prefix>/log.json）组装配置，代替 Key
+	Prefix string `mapstructure:"prefix" json:"prefix" yaml:"prefix"`
+	// Format 配置内容格式 yaml | json | toml，为空时按 key 扩展名或内容自动识别
+	Format string `mapstructure:"format" json:"format" yaml:"format"`
+	// Token ACL token，优先级 Token > TokenFile > TokenEnv 指定的环境变量 > CONSUL_HTTP_TOKEN
+	Token      string `mapstructure:"token"      json:"token"      yaml:"token"`
+	TokenFile  string `mapstructure:"token_file" json:"token_file" yaml:"token_file"`
+	TokenEnv   string `mapstructure:"token_env"  json:"token_env"  yaml:"token_env"`
+	Datacenter string `mapstructure:"datacenter" json:"datacenter" yaml:"datacenter"`
+	Namespace  string `mapstructure:"namespace"  json:"namespace"  yaml:"namespace"` // Consul 企业版
+	// TLS 设置后使用 https；证书与私钥必须成对，热更新时整体替换而不是逐字段合并
+	TLS *ConsulTLS `mapstructure:"tls" json:"tls" yaml:"tls" merge:"replace"`
+}
+
+type ConsulTLS struct {
+	CAFile             string `mapstructure:"ca_file"              json:"ca_file"              yaml:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"            json:"cert_file"            yaml:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"             json:"key_file"             yaml:"key_file"`
+	ServerName         string `mapstructure:"server_name"          json:"server_name"          yaml:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
 }
 
 type LogConfig struct {
@@ -42,3 +71,59 @@ type Repo struct {
 	Address string `mapstructure:"address" json:"address" yaml:"address"`
 	Path    string `mapstructure:"path"    json:"path"    yaml:"path"`
 }
+
+type ArgoConfig struct {
+	ArgoTarget   `mapstructure:",squash" yaml:",inline"`
+	Namespace    string                 `mapstructure:"namespace"    json:"namespace"    yaml:"namespace"` // ArgoCD 所在 namespace
+	Environments map[string]*ArgoTarget `mapstructure:"environments" json:"environments" yaml:"environments"`
+	Applications map[string]*ArgoTarget `mapstructure:"applications" json:"applications" yaml:"applications"`
+	// ManageProjects 为每个 devflow project 维护同名 AppProject，Application 使用该 AppProject
+	ManageProjects bool `mapstructure:"manage_projects" json:"manage_projects" yaml:"manage_projects"`
+	// ProjectDefaults 所有 AppProject 的默认设置，Projects 按 project 名称覆盖
+	ProjectDefaults *ArgoProjectConfig            `mapstructure:"project_defaults" json:"project_defaults" yaml:"project_defaults"`
+	Projects        map[string]*ArgoProjectConfig `mapstructure:"projects"         json:"projects"         yaml:"projects"`
+}
+
+type ArgoProjectConfig struct {
+	SourceRepos []string `mapstructure:"source_repos" json:"source_repos" yaml:"source_repos"`
+	// Namespaces 允许部署的 namespace，为空时允许 Application 实际使用的 namespace（见 ArgoTarget.TargetNamespace）
+	Namespaces []string `mapstructure:"namespaces" json:"namespaces" yaml:"namespaces"`
+	// ClusterResourceWhitelist 允许的集群级资源，格式 group/kind，例如 rbac.authorization.k8s.io/ClusterRole
+	ClusterResourceWhitelist []string     `mapstructure:"cluster_resource_whitelist" json:"cluster_resource_whitelist" yaml:"cluster_resource_whitelist"`
+	SyncWindows              []SyncWindow `mapstructure:"sync_windows"               json:"sync_windows"               yaml:"sync_windows"`
+}
+
+type SyncWindow struct {
+	Kind         string   `mapstructure:"kind"         json:"kind"         yaml:"kind"`     // allow | deny
+	Schedule     string   `mapstructure:"schedule"     json:"schedule"     yaml:"schedule"` // cron 表达式
+	Duration     string   `mapstructure:"duration"     json:"duration"     yaml:"duration"` // 例如 1h、30m
+	Applications []string `mapstructure:"applications" json:"applications" yaml:"applications"`
+	Namespaces   []string `mapstructure:"namespaces"   json:"namespaces"   yaml:"namespaces"`
+	Clusters     []string `mapstructure:"clusters"     json:"clusters"     yaml:"clusters"`
+	ManualSync   bool     `mapstructure:"manual_sync"  json:"manual_sync"  yaml:"manual_sync"`
+	TimeZone     string   `mapstructure:"time_zone"    json:"time_zone"    yaml:"time_zone"`
+}
+
+type ArgoTarget struct {
+	Project string `mapstructure:"project" json:"project,omitempty" yaml:"project"`
+	Plugin  string `mapstructure:"plugin"  json:"plugin,omitempty"  yaml:"plugin"`
+	Server  string `mapstructure:"server"  json:"server,omitempty"  yaml:"server"`  // 目标集群地址
+	Cluster string `mapstructure:"cluster" json:"cluster,omitempty" yaml:"cluster"` // 目标集群名称（多集群）
+	// DestinationNamespace 部署的 namespace，支持 {project}、{env} 占位符，为空时为 <project>-<env>
+	DestinationNamespace string `mapstructure:"destination_namespace" json:"destination_namespace,omitempty" yaml:"destination_namespace"`
+}
+
+type ReleaseConfig struct {
+	// Windows 热更新时整体替换，保证删除的窗口立即失效
+	Windows []ReleaseWindow `mapstructure:"windows" json:"windows" yaml:"windows" merge:"replace"`
+}
+
+type ReleaseWindow struct {
+	Kind         string   `mapstructure:"kind"         json:"kind"         yaml:"kind"`     // allow | deny
+	Schedule     string   `mapstructure:"schedule"     json:"schedule"     yaml:"schedule"` // cron 表达式，窗口开始时间
+	Duration     string   `mapstructure:"duration"     json:"duration"     yaml:"duration"` // 窗口持续时间，例如 48h
+	TimeZone     string   `mapstructure:"time_zone"    json:"time_zone"    yaml:"time_zone"`
+	Projects     []string `mapstructure:"projects"     json:"projects"     yaml:"projects"`     // 为空表示所有 project
+	Environments []string `mapstructure:"environments" json:"environments" yaml:"environments"` // 为空表示所有环境
+	Description  string   `mapstructure:"description"  json:"description"  yaml:"description"`
+}
@@ -0,0 +1,9 @@
+package model
+
+// DefaultConfig 内置默认配置，是分层加载的最底层
+func DefaultConfig() *Config {
+	return &Config{
+		Server: &ServerConfig{Port: 8080},
+		Log:    &LogConfig{Level: "info", Format: "console"},
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EnvironmentState Application 在某个环境中当前生效的 manifest
+type EnvironmentState struct {
+	ManifestID   primitive.ObjectID `bson:"manifest_id" json:"manifest_id"`
+	ManifestName string             `bson:"manifest_name" json:"manifest_name"`
+	JobID        primitive.ObjectID `bson:"job_id" json:"job_id"`
+	DeployedAt   time.Time          `bson:"deployed_at" json:"deployed_at"`
+}
+
+// PromotionOrder 环境晋级顺序，例如 dev -> staging -> prod
+func PromotionOrder() []string {
+	c := Current()
+	if c == nil {
+		return nil
+	}
+	return c.Environments
+}
+
+// NextEnvironment 返回 env 在晋级顺序中的下一个环境，没有时返回空
+func NextEnvironment(env string) string {
+	order := PromotionOrder()
+	i := slices.Index(order, env)
+	if i < 0 || i+1 >= len(order) {
+		return ""
+	}
+	return order[i+1]
+}
+
+// ValidatePromotion 校验 from -> to 是否符合晋级顺序，未配置顺序时不限制
+func ValidatePromotion(from, to string) error {
+	if from == to {
+		return fmt.Errorf("cannot promote %s to itself", from)
+	}
+	order := PromotionOrder()
+	if len(order) == 0 {
+		return nil
+	}
+	if !slices.Contains(order, to) {
+		return fmt.Errorf("unknown environment %s", to)
+	}
+	if next := NextEnvironment(from); next != to {
+		return fmt.Errorf("environment %s can only be promoted to %q", from, next)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	current  atomic.Pointer[Config]
+	updateMu sync.Mutex
+)
+
+// Current 返回当前配置的快照，可以在任意 goroutine 中无锁读取
+// 快照是只读的：调用方不得修改，需要修改请使用 UpdateConfig
+// 未通过 SetConfig/UpdateConfig 设置时回退到直接赋值的 C
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return C
+}
+
+// SetConfig 整体替换当前配置，只应在启动加载完成、其它 goroutine 读取配置之前调用一次
+func SetConfig(c *Config) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	current.Store(c)
+	C = c
+}
+
+// UpdateConfig copy-on-write 更新配置：fn 在当前配置的副本上修改，返回 error 时放弃本次更新
+// 更新之间互斥，读取方只会看到更新前或更新后的完整配置，返回更新前后的快照
+func UpdateConfig(fn func(c *Config) error) (old, updated *Config, err error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	old = Current()
+	updated = old.Clone()
+	if updated == nil {
+		updated = &Config{}
+	}
+	if err := fn(updated); err != nil {
+		return old, old, err
+	}
+	current.Store(updated)
+	return old, updated, nil
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestUpdateConfigConcurrentReads(t *testing.T) {
+	initial := &Config{Env: "dev", Log: &LogConfig{Level: "info"}}
+	SetConfig(initial)
+	t.Cleanup(func() { SetConfig(nil) })
+
+	const updates = 200
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c := Current()
+				if c.Env == "" || c.Log == nil || c.Log.Level == "" {
+					t.Errorf("read a partially updated config: %+v", c)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < updates; i++ {
+		_, _, err := UpdateConfig(func(c *Config) error {
+			c.Env = fmt.Sprintf("env-%d", i)
+			c.Log.Level = fmt.Sprintf("level-%d", i)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if got := Current(); got.Env != fmt.Sprintf("env-%d", updates-1) {
+		t.Errorf("env = %q, want the last update", got.Env)
+	}
+	if C != initial || initial.Env != "dev" || initial.Log.Level != "info" {
+		t.Errorf("UpdateConfig changed the startup config: %+v", C)
+	}
+}
+
+func TestUpdateConfigErrorKeepsSnapshot(t *testing.T) {
+	SetConfig(&Config{Env: "dev"})
+	t.Cleanup(func() { SetConfig(nil) })
+
+	before := Current()
+	old, updated, err := UpdateConfig(func(c *Config) error {
+		c.Env = "prod"
+		return fmt.Errorf("invalid")
+	})
+	if err == nil {
+		t.Fatal("expected the error from fn")
+	}
+	if old != before || updated != before || Current() != before || before.Env != "dev" {
+		t.Errorf("failed update replaced the snapshot: %+v", Current())
+	}
+}
@@ -1,10 +1,13 @@
 package model
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
 	appv1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"os"
 )
 
 type JobStatus string
@@ -21,9 +24,41 @@ const (
 	JobUpgrade  string = "Upgrade"
 	JobRollback string = "Rollback"
 
-	project = "app"
+	// JobIDLabel ArgoCD Application 上关联 Job 的 label
+	JobIDLabel = "job_id"
+	EnvLabel   = "devflow.io/env"
 )
 
+// jobTransitions Job 状态机，key 为当前状态，value 为允许进入的状态
+var jobTransitions = map[JobStatus][]JobStatus{
+	JobPending:     {JobRunning, JobSucceeded, JobFailed, JobRollingBack},
+	JobRunning:     {JobSucceeded, JobFailed, JobRollingBack},
+	JobFailed:      {JobRollingBack},
+	JobRollingBack: {JobRolledBack, JobFailed},
+}
+
+// CanTransitionTo 是否允许从当前状态进入 next，相同状态视为允许
+func (s JobStatus) CanTransitionTo(next JobStatus) bool {
+	if s == next {
+		return true
+	}
+	return slices.Contains(jobTransitions[s], next)
+}
+
+// IsTerminal 是否为终态
+func (s JobStatus) IsTerminal() bool {
+	return s == JobSucceeded || s == JobRolledBack
+}
+
+// TransitionTo 校验并修改 Job 状态
+func (j *Job) TransitionTo(next JobStatus) error {
+	if !j.Status.CanTransitionTo(next) {
+		return fmt.Errorf("job %s: invalid status transition %s -> %s", j.ID.Hex(), j.Status, next)
+	}
+	j.Status = next
+	return nil
+}
+
 type Job struct {
 	BaseModel `bson:",inline"`
 
@@ -34,12 +69,48 @@ type Job struct {
 	ManifestName    string             `bson:"manifest_name" json:"manifest_name"`
 	Type            string             `bson:"type" json:"type"`
 	Status          JobStatus          `bson:"status" json:"status"`
+	// Env 目标环境，为空时使用 Environment()（兼容旧的 Job）
+	Env string `bson:"env,omitempty" json:"env,omitempty"`
+	// StatusReason 当前状态的原因，例如因发布窗口而 Pending
+	StatusReason string `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	// WindowOverride 手动跳过发布窗口检查
+	WindowOverride bool `bson:"window_override,omitempty" json:"window_override,omitempty"`
+	// RollbackFrom 回滚 Job 对应的失败 Job
+	RollbackFrom *primitive.ObjectID `bson:"rollback_from,omitempty" json:"rollback_from,omitempty"`
+	// AppliedAt Application 被更新（开始发布）的时间，用于区分本次发布和历史 operation
+	AppliedAt *time.Time `bson:"applied_at,omitempty" json:"applied_at,omitempty"`
 }
 
 func (j *Job) CollectionName() string { return "job" }
 
+// TargetEnv Job 实际部署的环境
+func (j *Job) TargetEnv() string {
+	if j.Env != "" {
+		return j.Env
+	}
+	return Environment()
+}
+
+// ArgoApplicationName 每个 application + env 对应一个 ArgoCD Application
+// 没有显式指定环境的旧 Job 仍使用 application 名称
+func (j *Job) ArgoApplicationName() string {
+	return ArgoApplicationName(j.ApplicationName, j.Env)
+}
+
+func ArgoApplicationName(application, env string) string {
+	if env == "" {
+		return application
+	}
+	return application + "-" + env
+}
+
 func (j *Job) GenerateApplication() *appv1.Application {
-	env := os.Getenv("Env")
+	env := j.TargetEnv()
+	cfg := GetArgoConfig()
+	target := cfg.Resolve(j.ApplicationName, env)
+	if cfg.ManageProjects && j.ProjectName != "" {
+		target.Project = j.ProjectName
+	}
 
 	manifestID := j.ManifestID.Hex()
 	app := &appv1.Application{
@@ -48,18 +119,20 @@ func (j *Job) GenerateApplication() *appv1.Application {
 			APIVersion: "argoproj.io/v1alpha1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: j.ApplicationName,
+			Name: j.ArgoApplicationName(),
 			Labels: map[string]string{
-				"job_id": j.ID.Hex(),
+				JobIDLabel:       j.ID.Hex(),
+				ApplicationLabel: j.ApplicationName,
+				EnvLabel:         env,
 			},
 		},
 		Spec: appv1.ApplicationSpec{
-			Project: project,
+			Project: target.Project,
 			Source: &appv1.ApplicationSource{
 				RepoURL: manifestRepo.Address,
 				Path:    "./",
 				Plugin: &appv1.ApplicationSourcePlugin{
-					Name: "plugin",
+					Name: target.Plugin,
 					Parameters: []appv1.ApplicationSourcePluginParameter{
 						appv1.ApplicationSourcePluginParameter{
 							Name:    "env",
@@ -73,8 +146,9 @@ func (j *Job) GenerateApplication() *appv1.Application {
 				},
 			},
 			Destination: appv1.ApplicationDestination{
-				Server:    "https://kubernetes.default.svc",
-				Namespace: j.ProjectName,
+				Server:    target.Server,
+				Name:      target.Cluster,
+				Namespace: target.TargetNamespace(j.ProjectName, j.Env),
 			},
 			SyncPolicy: &appv1.SyncPolicy{
 				Automated: &appv1.SyncPolicyAutomated{
@@ -32,8 +32,13 @@ const (
 )
 
 const (
-	TraceIDAnnotation = "otel.devflow.io/trace-id"
-	SpanAnnotation    = "otel.devflow.io/parent-span-id"
+	TraceIDAnnotation     = "otel.devflow.io/trace-id"
+	SpanAnnotation        = "otel.devflow.io/parent-span-id"
+	TraceParentAnnotation = "otel.devflow.io/traceparent" // W3C traceparent
+	TraceStateAnnotation  = "otel.devflow.io/tracestate"  // W3C tracestate
+
+	ApplicationLabel = "devflow.io/application"
+	ManifestIDLabel  = "devflow.io/manifest-id"
 )
 
 type Manifest struct {
@@ -52,6 +57,10 @@ type Manifest struct {
 	PipelineID      string              `json:"pipeline_id" bson:"pipeline_id"` // Tekton PipelineRun ID
 	Steps           []ManifestStep      `json:"steps" bson:"steps"`             // 每个步骤状态
 	Status          ManifestStatus      `json:"status" bson:"status"`           // running, success, failed
+	// 构建产物（来自 Tekton results）
+	ImageRef    string `json:"image_ref,omitempty" bson:"image_ref,omitempty"`       // registry/name:tag
+	ImageDigest string `json:"image_digest,omitempty" bson:"image_digest,omitempty"` // sha256:...
+	CommitSHA   string `json:"commit_sha,omitempty" bson:"commit_sha,omitempty"`     // 实际构建的 git commit
 }
 
 type ManifestStep struct {
@@ -90,6 +99,7 @@ func (m *Manifest) GeneratePipelineRun(pipelineName string, pvc string) *tknv1.P
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: pipelineName + "-run-",
+			Labels:       m.PipelineRunLabels(),
 		},
 		Spec: tknv1.PipelineRunSpec{
 			PipelineRef: &tknv1.PipelineRef{
@@ -121,6 +131,18 @@ func (m *Manifest) GeneratePipelineRun(pipelineName string, pvc string) *tknv1.P
 	return pipelineRun
 }
 
+// PipelineRunLabels 关联 PipelineRun 与 Application / Manifest
+func (m *Manifest) PipelineRunLabels() map[string]string {
+	labels := map[string]string{}
+	if m.ApplicationName != "" {
+		labels[ApplicationLabel] = m.ApplicationName
+	}
+	if !m.ID.IsZero() {
+		labels[ManifestIDLabel] = m.ID.Hex()
+	}
+	return labels
+}
+
 func (m *Manifest) GeneratePipelineRunParams() []tknv1.Param {
 
 	imageTag := m.Name
@@ -0,0 +1,177 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	WindowAllow = "allow"
+	WindowDeny  = "deny"
+)
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// WindowDecision 发布窗口检查结果
+type WindowDecision struct {
+	Allowed bool
+	Reason  string
+	// NextOpen 预计可以发布的时间，未知时为零值
+	NextOpen time.Time
+}
+
+// GetReleaseConfig 未配置时返回空策略（不限制）
+func GetReleaseConfig() *ReleaseConfig {
+	c := Current()
+	if c == nil || c.Release == nil {
+		return &ReleaseConfig{}
+	}
+	return c.Release
+}
+
+// maxNextOpenSteps 推算 NextOpen 时最多跳过的窗口次数，超过后视为未知
+const maxNextOpenSteps = 64
+
+// CheckWindow 检查 project 在 env 下 now 时刻能否发布
+// 与 ArgoCD sync window 语义一致：任一 deny 生效则禁止；存在 allow 时必须处于某个 allow 内
+// 禁止时 NextOpen 为既不在 deny 内、又处于 allow 内（没有 allow 时不要求）的最早时间
+func (c *ReleaseConfig) CheckWindow(project, env string, now time.Time) (WindowDecision, error) {
+	state, err := c.windowState(project, env, now)
+	if err != nil {
+		return WindowDecision{}, err
+	}
+	if state.open() {
+		return WindowDecision{Allowed: true}, nil
+	}
+
+	// 依次跳到 deny 结束或下一个 allow 开始，直到两者同时满足
+	var nextOpen time.Time
+	next := state
+	for i := 0; i < maxNextOpenSteps; i++ {
+		at := next.until()
+		if at.IsZero() {
+			break
+		}
+		if next, err = c.windowState(project, env, at); err != nil {
+			return WindowDecision{}, err
+		}
+		if next.open() {
+			nextOpen = at
+			break
+		}
+	}
+
+	opens := "unknown"
+	if !nextOpen.IsZero() {
+		opens = nextOpen.Format(time.RFC3339)
+	}
+	if !state.denyEnd.IsZero() {
+		return WindowDecision{
+			Reason:   fmt.Sprintf("release frozen by deny window %s until %s", state.denyDesc, opens),
+			NextOpen: nextOpen,
+		}, nil
+	}
+	return WindowDecision{
+		Reason:   fmt.Sprintf("outside release window, next window opens at %s", opens),
+		NextOpen: nextOpen,
+	}, nil
+}
+
+// windowState 某一时刻匹配 project/env 的窗口状态
+type windowState struct {
+	hasAllow  bool
+	inAllow   bool
+	nextAllow time.Time
+	denyEnd   time.Time
+	denyDesc  string
+}
+
+// open 是否可以发布
+func (s windowState) open() bool {
+	return s.denyEnd.IsZero() && (!s.hasAllow || s.inAllow)
+}
+
+// until 不能发布时，状态可能变化的下一个时间点
+func (s windowState) until() time.Time {
+	if !s.denyEnd.IsZero() {
+		return s.denyEnd
+	}
+	return s.nextAllow
+}
+
+func (c *ReleaseConfig) windowState(project, env string, now time.Time) (windowState, error) {
+	var state windowState
+	for _, w := range c.Windows {
+		if !w.matches(project, env) {
+			continue
+		}
+		active, start, end, err := w.active(now)
+		if err != nil {
+			return windowState{}, err
+		}
+
+		switch w.Kind {
+		case WindowDeny:
+			if active && end.After(state.denyEnd) {
+				state.denyEnd = end
+				state.denyDesc = w.describe()
+			}
+		case WindowAllow:
+			state.hasAllow = true
+			if active {
+				state.inAllow = true
+			} else if state.nextAllow.IsZero() || start.Before(state.nextAllow) {
+				state.nextAllow = start
+			}
+		default:
+			return windowState{}, fmt.Errorf("invalid release window kind %q", w.Kind)
+		}
+	}
+	return state, nil
+}
+
+func (w ReleaseWindow) matches(project, env string) bool {
+	if len(w.Projects) > 0 && !slices.Contains(w.Projects, project) {
+		return false
+	}
+	if len(w.Environments) > 0 && !slices.Contains(w.Environments, env) {
+		return false
+	}
+	return true
+}
+
+// active 窗口在 now 是否生效；生效时返回本次窗口的开始与结束时间，未生效时 start 为下一次开始时间
+func (w ReleaseWindow) active(now time.Time) (bool, time.Time, time.Time, error) {
+	schedule, err := cronParser.Parse(w.Schedule)
+	if err != nil {
+		return false, time.Time{}, time.Time{}, fmt.Errorf("invalid release window schedule %q: %w", w.Schedule, err)
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil || duration <= 0 {
+		return false, time.Time{}, time.Time{}, fmt.Errorf("invalid release window duration %q", w.Duration)
+	}
+	if w.TimeZone != "" {
+		loc, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return false, time.Time{}, time.Time{}, fmt.Errorf("invalid release window time zone %q: %w", w.TimeZone, err)
+		}
+		now = now.In(loc)
+	}
+
+	// 从 now-duration 往后找第一次触发，如果不晚于 now，说明窗口正在生效
+	start := schedule.Next(now.Add(-duration))
+	if !start.After(now) {
+		return true, start, start.Add(duration), nil
+	}
+	return false, start, start.Add(duration), nil
+}
+
+func (w ReleaseWindow) describe() string {
+	if w.Description != "" {
+		return fmt.Sprintf("%q", w.Description)
+	}
+	return fmt.Sprintf("%q", w.Schedule)
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckWindow(t *testing.T) {
+	// 2024-06-01 是周六
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	weekendDeny := ReleaseWindow{Kind: WindowDeny, Schedule: "0 0 * * 6", Duration: "48h", Description: "weekend"}
+	weekdayAllow := ReleaseWindow{Kind: WindowAllow, Schedule: "0 9 * * 1-5", Duration: "8h"}
+
+	tests := []struct {
+		name     string
+		windows  []ReleaseWindow
+		env      string
+		now      time.Time
+		allowed  bool
+		nextOpen time.Time
+		reason   string
+	}{
+		{
+			name:    "no windows",
+			now:     at(1, 12, 0),
+			allowed: true,
+		},
+		{
+			name:     "deny only opens at deny end",
+			windows:  []ReleaseWindow{weekendDeny},
+			now:      at(1, 12, 0),
+			nextOpen: at(3, 0, 0),
+			reason:   `deny window "weekend"`,
+		},
+		{
+			name:     "deny opens at next allow start",
+			windows:  []ReleaseWindow{weekendDeny, weekdayAllow},
+			now:      at(1, 12, 0),
+			nextOpen: at(3, 9, 0),
+			reason:   "until 2024-06-03T09:00:00Z",
+		},
+		{
+			name:    "inside allow",
+			windows: []ReleaseWindow{weekendDeny, weekdayAllow},
+			now:     at(3, 10, 0),
+			allowed: true,
+		},
+		{
+			name:     "outside allow",
+			windows:  []ReleaseWindow{weekendDeny, weekdayAllow},
+			now:      at(3, 18, 0),
+			nextOpen: at(4, 9, 0),
+			reason:   "outside release window",
+		},
+		{
+			name: "overlapping deny windows",
+			windows: []ReleaseWindow{
+				weekendDeny,
+				{Kind: WindowDeny, Schedule: "0 12 * * 0", Duration: "24h"},
+			},
+			now:      at(1, 12, 0),
+			nextOpen: at(3, 12, 0),
+		},
+		{
+			name: "deny inside allow",
+			windows: []ReleaseWindow{
+				{Kind: WindowAllow, Schedule: "0 9 * * *", Duration: "8h"},
+				{Kind: WindowDeny, Schedule: "0 12 * * *", Duration: "1h"},
+			},
+			now:      at(3, 12, 30),
+			nextOpen: at(3, 13, 0),
+		},
+		{
+			name:     "allow in another time zone",
+			windows:  []ReleaseWindow{{Kind: WindowAllow, Schedule: "0 9 * * 1-5", Duration: "8h", TimeZone: "Asia/Shanghai"}},
+			now:      at(3, 0, 30),
+			nextOpen: at(3, 1, 0),
+		},
+		{
+			name:    "inside allow in another time zone",
+			windows: []ReleaseWindow{{Kind: WindowAllow, Schedule: "0 9 * * 1-5", Duration: "8h", TimeZone: "Asia/Shanghai"}},
+			now:     at(3, 2, 0),
+			allowed: true,
+		},
+		{
+			name:    "window for another environment",
+			windows: []ReleaseWindow{{Kind: WindowDeny, Schedule: "0 0 * * 6", Duration: "48h", Environments: []string{"prod"}}},
+			env:     "dev",
+			now:     at(1, 12, 0),
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &ReleaseConfig{Windows: tt.windows}
+			decision, err := cfg.CheckWindow("demo", tt.env, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed != tt.allowed {
+				t.Fatalf("allowed = %v, want %v (%s)", decision.Allowed, tt.allowed, decision.Reason)
+			}
+			if !decision.NextOpen.Equal(tt.nextOpen) {
+				t.Errorf("next open = %s, want %s", decision.NextOpen, tt.nextOpen)
+			}
+			if !strings.Contains(decision.Reason, tt.reason) {
+				t.Errorf("reason = %q, want it to contain %q", decision.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCheckWindowInvalid(t *testing.T) {
+	tests := []ReleaseWindow{
+		{Kind: "freeze", Schedule: "0 0 * * *", Duration: "1h"},
+		{Kind: WindowDeny, Schedule: "not a cron", Duration: "1h"},
+		{Kind: WindowDeny, Schedule: "0 0 * * *", Duration: "0s"},
+		{Kind: WindowDeny, Schedule: "0 0 * * *", Duration: "1h", TimeZone: "Nowhere/City"},
+	}
+	for _, w := range tests {
+		cfg := &ReleaseConfig{Windows: []ReleaseWindow{w}}
+		if _, err := cfg.CheckWindow("demo", "dev", time.Now()); err == nil {
+			t.Errorf("window %+v: expected an error", w)
+		}
+	}
+}
@@ -6,6 +6,11 @@ func InitConfigRepo(c *Repo) {
 	manifestRepo = c
 }
 
+// LookupConfigRepo 返回 InitConfigRepo 设置的配置仓库，未初始化时返回 nil
+func LookupConfigRepo() *Repo {
+	return manifestRepo
+}
+
 func GetConfigRepo() *Repo {
 	if manifestRepo == nil {
 		panic("config repo not initialized")
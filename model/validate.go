@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	logFormats = []string{"console", "json"}
+	logLevels  = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+	// consulFormats 与 consul.FormatYAML 等常量一致
+	consulFormats = []string{"yaml", "json", "toml"}
+)
+
+// Validate 校验配置，返回所有问题（errors.Join）
+// 只校验已设置的配置段，未设置的段由使用方决定是否必需
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server != nil && (c.Server.Port <= 0 || c.Server.Port > 65535) {
+		add("server.port: %d out of range 1-65535", c.Server.Port)
+	}
+
+	if c.Mongo != nil {
+		if c.Mongo.URI == "" {
+			add("mongo.uri: required")
+		} else if !strings.HasPrefix(c.Mongo.URI, "mongodb://") && !strings.HasPrefix(c.Mongo.URI, "mongodb+srv://") {
+			add("mongo.uri: must start with mongodb:// or mongodb+srv://")
+		}
+		if c.Mongo.DBName == "" {
+			add("mongo.db: required")
+		}
+	}
+
+	if c.Log != nil {
+		if c.Log.Format != "" && !slices.Contains(logFormats, c.Log.Format) {
+			add("log.format: %q must be one of %s", c.Log.Format, strings.Join(logFormats, ", "))
+		}
+		if c.Log.Level != "" && !slices.Contains(logLevels, strings.ToLower(c.Log.Level)) {
+			add("log.level: %q must be one of %s", c.Log.Level, strings.Join(logLevels, ", "))
+		}
+	}
+
+	if c.Otel != nil && c.Otel.ServiceName == "" {
+		add("otel.service_name: required")
+	}
+
+	if c.Repo != nil && c.Repo.Address == "" {
+		add("repo.address: required")
+	}
+
+	if c.Consul != nil {
+		if c.Consul.Address != "" && c.Consul.Key == "" && c.Consul.Prefix == "" {
+			add("consul.key: key or prefix required when consul.address is set")
+		}
+		if c.Consul.Key != "" && c.Consul.Prefix != "" {
+			add("consul.prefix: key and prefix are mutually exclusive")
+		}
+		if c.Consul.Format != "" && !slices.Contains(consulFormats, c.Consul.Format) {
+			add("consul.format: %q must be one of %s", c.Consul.Format, strings.Join(consulFormats, ", "))
+		}
+		if tls := c.Consul.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+			add("consul.tls: cert_file and key_file must be set together")
+		}
+	}
+
+	if c.Release != nil {
+		for i, w := range c.Release.Windows {
+			if w.Kind != WindowAllow && w.Kind != WindowDeny {
+				add("release.windows[%d].kind: %q must be allow or deny", i, w.Kind)
+			}
+			if _, _, _, err := w.active(time.Now()); err != nil {
+				add("release.windows[%d]: %v", i, err)
+			}
+		}
+	}
+
+	if len(c.Environments) > 0 {
+		seen := map[string]bool{}
+		for _, env := range c.Environments {
+			if env == "" || seen[env] {
+				add("environments: %q empty or duplicated", env)
+			}
+			seen[env] = true
+		}
+	}
+
+	return errors.Join(errs...)
+}